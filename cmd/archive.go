@@ -1,22 +1,37 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
 	"github.com/tanema/cws/lib/archive"
-	"github.com/tanema/cws/lib/term"
+	"github.com/tanema/cws/lib/hooks"
 )
 
 var archiveCmd = &cobra.Command{
 	Use:   "archive [dir-path]",
 	Args:  cobra.ExactArgs(1),
 	Short: "zip the dist directory, update the manifest version at the same time",
-	Run: func(cmd *cobra.Command, args []string) {
-		version := getVersion(cmd)
-		path := archiveExt(args[0], version, getString(cmd, "json"))
-		term.Println(`✅ {{.Version | bold}} {{"Archive Created At:" | green}} {{.Path | cyan}}`, struct {
-			Version string
-			Path    string
-		}{version, path})
+	Long: `Archive runs the prebuild hook, zips the directory with the version set in
+the manifest, checks the size budgets and runs the postarchive hook.`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		res := &result{}
+		if res.Version, err = getVersion(cmd); err != nil {
+			return fail(cmd, res, err)
+		}
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, res, err)
+		}
+		res.ExtensionID = config.ExtID
+		t := target{config: config, source: args[0], manifestPatch: getString(cmd, "json"), public: true}
+		if err := buildArchive(cmd, t, res); err != nil {
+			runFailureHook(cmd, t, res, err)
+			return fail(cmd, res, err)
+		}
+		return render(cmd, `✅ {{.Version | bold}} {{"Archive Created At:" | green}} {{.ArchivePath | cyan}}`, res)
 	},
 }
 
@@ -26,11 +41,50 @@ func init() {
 	archiveCmd.Flags().StringP("json", "j", "", "json changes to the manifest. Should be formatted by key:value comma separated")
 }
 
-func archiveExt(dirPath, version, jsonChangeset string) (archivePath string) {
-	var err error
-	cobra.CheckErr(term.Spinner("Creating Archive", func() error {
-		archivePath, err = archive.Zip(dirPath, version, jsonChangeset)
+// buildArchive runs the prebuild hook, archives the source of the target,
+// checks it against the size budgets and then runs the postarchive hook
+func buildArchive(cmd *cobra.Command, t target, res *result) error {
+	if err := runHook(cmd, t, res, hooks.Prebuild, nil); err != nil {
+		return err
+	} else if err := archiveExt(res, t.source, t.manifestPatch); err != nil {
+		return err
+	} else if err := checkBudgets(cmd, t.config, res); err != nil {
+		return err
+	}
+	return runHook(cmd, t, res, hooks.Postarchive, nil)
+}
+
+// removeArchive deletes the archive of a release once it is done with, also
+// when the build failed after the archive was written
+func removeArchive(res *result) {
+	if res.ArchivePath != "" {
+		os.Remove(res.ArchivePath)
+	}
+}
+
+// archiveExt zips the extension at dirPath with the result version and records
+// where the archive was written, its size and checksum, and the commit it was
+// built from on the result
+func archiveExt(res *result, dirPath, jsonChangeset string) error {
+	if dirPath == "" {
+		return withCode(exitConfig, errors.New("no extension directory was given, pass one or set source in the config"))
+	}
+	dest := "compiled_extension.zip"
+	if res.Name != "" {
+		dest = fmt.Sprintf("compiled_extension_%v.zip", res.Name)
+	}
+	return spinner(res.Name, "Creating Archive", func() error {
+		var err error
+		if res.ArchivePath, err = archive.ZipTo(dest, dirPath, res.Version, jsonChangeset); err != nil {
+			return err
+		}
+		info, err := os.Stat(res.ArchivePath)
+		if err != nil {
+			return err
+		}
+		res.ArchiveSize = info.Size()
+		res.Commit = gitCommit(dirPath)
+		res.ArchiveHash, err = archive.SHA256(res.ArchivePath)
 		return err
-	}))
-	return archivePath
+	})
 }
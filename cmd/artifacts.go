@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/term"
+)
+
+const artifactsListTmpl = `{{if .Artifacts}}{{printf "%-20v %-16v %-12v %10v  %-8v %v" "Extension" "Version" "SHA-256" "Size" "Commit" "Created" | bold}}
+{{- range .Artifacts}}
+{{printf "%-20v %-16v %-12.12v %10v  %-8v %v" (or .Name .ExtensionID) .Version .SHA256 .Size (or (printf "%.8s" .Commit) "-") (.Created.Local.Format "2006-01-02 15:04:05")}}
+{{- end}}
+{{- else}}{{"No artifacts in" | faint}} {{.Dir | cyan}}{{end}}`
+
+const artifactsPruneTmpl = `{{if .DryRun}}🧪 {{"Would prune" | yellow}}{{else}}🧹 {{"Pruned" | green}}{{end}} {{len .Artifacts}} artifacts from {{.Dir | cyan}}
+{{- range .Artifacts}}
+  {{or .Name .ExtensionID}} {{.Version | bold}} {{printf "%.12s" .SHA256 | faint}}{{end}}`
+
+type (
+	artifactsReport struct {
+		Dir       string               `json:"dir"`
+		DryRun    bool                 `json:"dry_run,omitempty"`
+		Artifacts []artifacts.Metadata `json:"artifacts"`
+	}
+	artifactResult struct {
+		artifacts.Metadata
+		Path string `json:"path"`
+	}
+)
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "list, fetch and prune the archives kept from uploads",
+}
+
+var artifactsListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "list the kept archives, oldest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		extID := ""
+		if getString(cmd, "extension") != "" {
+			if extID, err = artifactExtension(cmd, config); err != nil {
+				return fail(cmd, &result{}, err)
+			}
+		}
+		report := artifactsReport{Dir: artifactDir(config)}
+		if report.Artifacts, err = artifactStore(config).List(extID); err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		return render(cmd, artifactsListTmpl, report)
+	},
+}
+
+var artifactsGetCmd = &cobra.Command{
+	Use:   "get [version|sha256]",
+	Args:  cobra.ExactArgs(1),
+	Short: "copy a kept archive out of the store, by version or a sha256 prefix",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		extID, err := artifactExtension(cmd, config)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		store := artifactStore(config)
+		meta, err := artifacts.Find(store, extID, args[0])
+		if err != nil {
+			return fail(cmd, &result{ExtensionID: extID}, err)
+		}
+		res := artifactResult{Metadata: meta, Path: getString(cmd, "out")}
+		if res.Path == "" {
+			res.Path = fmt.Sprintf("%v-%v.zip", meta.ExtensionID, meta.Version)
+		}
+		if err := store.Fetch(meta, res.Path); err != nil {
+			return fail(cmd, &result{ExtensionID: extID}, err)
+		}
+		return render(cmd, `✅ {{.Version | bold}} {{"Artifact Saved At:" | green}} {{.Path | cyan}}{{with .Commit}} built from {{. | faint}}{{end}}`, res)
+	},
+}
+
+var artifactsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Args:  cobra.NoArgs,
+	Short: "remove the archives that the retention policy does not keep, the newest archive of each extension is always kept",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		policy, err := config.Artifacts.Retention()
+		if err != nil {
+			return fail(cmd, &result{}, withCode(exitConfig, err))
+		}
+		if cmd.Flags().Changed("keep") {
+			policy.Keep, _ = cmd.Flags().GetInt("keep")
+		}
+		if maxAge := getString(cmd, "max-age"); maxAge != "" {
+			if policy.MaxAge, err = time.ParseDuration(maxAge); err != nil {
+				return fail(cmd, &result{}, fmt.Errorf("invalid --max-age %q, expected a duration like 720h", maxAge))
+			}
+		}
+		if policy.Keep <= 0 && policy.MaxAge <= 0 {
+			return fail(cmd, &result{}, withCode(exitConfig, errors.New("no retention policy, set artifacts.keep or artifacts.max_age in the config or pass --keep or --max-age")))
+		}
+		report := artifactsReport{Dir: artifactDir(config), DryRun: isDryRun(cmd)}
+		if report.Artifacts, err = artifacts.Prune(artifactStore(config), policy, time.Now(), report.DryRun); err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		return render(cmd, artifactsPruneTmpl, report)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(artifactsCmd)
+	artifactsCmd.AddCommand(artifactsListCmd, artifactsGetCmd, artifactsPruneCmd)
+	for _, cmd := range []*cobra.Command{artifactsListCmd, artifactsGetCmd} {
+		cmd.Flags().String("extension", "", "name or id of the extension (default: the extension in the config)")
+	}
+	artifactsGetCmd.Flags().StringP("out", "O", "", "where to write the archive (default: <extension id>-<version>.zip)")
+	artifactsPruneCmd.Flags().Int("keep", 0, "how many archives of each extension to keep, overrides artifacts.keep")
+	artifactsPruneCmd.Flags().String("max-age", "", "remove archives older than a duration like 720h, overrides artifacts.max_age")
+}
+
+func artifactDir(config *gcloud.Config) string {
+	if config.Artifacts.Dir != "" {
+		return config.Artifacts.Dir
+	}
+	return artifacts.DefaultDir()
+}
+
+// artifactStore is the store of uploaded archives of the config
+func artifactStore(config *gcloud.Config) artifacts.Store {
+	return artifacts.Local{Dir: artifactDir(config)}
+}
+
+// artifactExtension is the id of the extension picked with --extension, by
+// name or id, or the only extension of the config
+func artifactExtension(cmd *cobra.Command, config *gcloud.Config) (string, error) {
+	name := getString(cmd, "extension")
+	for _, ext := range config.Extensions {
+		if ext.Name == name || (name == "" && len(config.Extensions) == 1) {
+			return ext.ExtID, nil
+		}
+	}
+	if name != "" {
+		return name, nil
+	} else if config.ExtID != "" && len(config.Extensions) == 0 {
+		return config.ExtID, nil
+	}
+	return "", withCode(exitConfig, errors.New("pick the extension with --extension"))
+}
+
+// cacheArtifact keeps the uploaded archive so that it can be fetched or
+// published again with rollback, then prunes the store when a retention
+// policy is configured. The archive is already uploaded so a failure is only
+// reported.
+func cacheArtifact(t target, res *result) {
+	meta := artifacts.Metadata{
+		ExtensionID: res.ExtensionID,
+		Name:        res.Name,
+		Version:     res.Version,
+		SHA256:      res.ArchiveHash,
+		Size:        res.ArchiveSize,
+		Commit:      res.Commit,
+	}
+	store := artifactStore(t.config)
+	err := func() (err error) {
+		if meta.Manifest, err = archive.ReadManifest(res.ArchivePath); err != nil {
+			return err
+		} else if err := store.Put(meta, res.ArchivePath); err != nil {
+			return err
+		}
+		policy, _ := t.config.Artifacts.Retention()
+		if policy.Keep > 0 || policy.MaxAge > 0 {
+			_, err = artifacts.Prune(store, policy, time.Now(), false)
+		}
+		return err
+	}()
+	if err != nil {
+		term.Println(`⚠️  {{. | yellow}}`, fmt.Sprintf("could not keep the archive: %v", err))
+	}
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/gcloud"
+)
+
+const configShowTmpl = `⚙️  {{"Config" | green}}{{with .Profile}} profile: {{. | bold}}{{end}}
+{{- range .Files}}
+  {{. | cyan}}{{else}}
+  {{"no config files found, only using the environment" | faint}}{{end}}
+{{printf "  %-40v %-32v %v" "Key" "Value" "Source" | bold}}
+{{- range .Values}}
+  {{printf "%-40v %-32v" .Key .Value}} {{.Source | faint}}{{end}}
+{{- with .Error}}
+{{"Invalid:" | red}} {{.}}{{end}}`
+
+const configValidateTmpl = `{{if .Valid}}✅ {{"Config is valid" | green}}{{if .CredentialsChecked}}, the credentials were accepted{{end}}
+{{- else}}🔥 {{"Config is invalid" | red}}{{range .Errors}}
+  {{.}}{{end}}{{end}}`
+
+type (
+	configValue struct {
+		Key    string `json:"key"`
+		Value  string `json:"value"`
+		Source string `json:"source"`
+	}
+	configReport struct {
+		Files   []string      `json:"files"`
+		Profile string        `json:"profile,omitempty"`
+		Values  []configValue `json:"values"`
+		Error   string        `json:"error,omitempty"`
+	}
+	configValidation struct {
+		Files              []string `json:"files"`
+		Profile            string   `json:"profile,omitempty"`
+		Valid              bool     `json:"valid"`
+		CredentialsChecked bool     `json:"credentials_checked"`
+		Errors             []string `json:"errors,omitempty"`
+	}
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "inspect and edit the cws configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Args:  cobra.NoArgs,
+	Short: "print the effective config, with secrets masked, and where each value came from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := gcloud.LoadConfig(configOptions(cmd))
+		if config == nil {
+			return fail(cmd, &result{}, withCode(exitConfig, err))
+		}
+		report := configReport{Files: config.Files, Profile: config.Profile}
+		if err != nil {
+			report.Error = err.Error()
+		}
+		for _, val := range config.Values() {
+			if val.Secret {
+				val.Value = maskSecret(val.Value)
+			}
+			report.Values = append(report.Values, configValue{Key: val.Key, Value: val.Value, Source: val.Source})
+		}
+		return render(cmd, configShowTmpl, report)
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Args:  cobra.ExactArgs(1),
+	Short: "print a single value from the effective config",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := gcloud.LoadConfig(configOptions(cmd))
+		if config == nil {
+			return fail(cmd, &result{}, withCode(exitConfig, err))
+		}
+		reveal, _ := cmd.Flags().GetBool("reveal")
+		for _, val := range config.Values() {
+			if val.Key != args[0] {
+				continue
+			} else if val.Secret && !reveal {
+				val.Value = maskSecret(val.Value)
+			}
+			return render(cmd, `{{.Value}}`, configValue{Key: val.Key, Value: val.Value, Source: val.Source})
+		}
+		return fail(cmd, &result{}, withCode(exitConfig, fmt.Errorf("%v is not set", args[0])))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [key] [value]",
+	Args:  cobra.ExactArgs(2),
+	Short: "set a value in the config file, use profiles.<name>.<key> or extensions.<name>.<key> for sections",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editConfig(cmd, func(file *gcloud.ConfigFile, key string) error {
+			if getString(cmd, "secret-store") != "" && !gcloud.IsSecretKey(key) {
+				return fmt.Errorf("%v is not a secret, --secret-store only applies to values like client_secret and refresh_token", args[0])
+			}
+			value, err := saveSecret(cmd, configOptions(cmd).Profile, args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return file.Set(key, value)
+		}, args[0])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Args:  cobra.ExactArgs(1),
+	Short: "remove a value from the config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editConfig(cmd, (*gcloud.ConfigFile).Unset, args[0])
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Args:  cobra.NoArgs,
+	Short: "check the config is complete, and with --check that the credentials are accepted",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := gcloud.LoadConfig(configOptions(cmd))
+		if err == nil {
+			_, err = configBudget(config)
+		}
+		report := configValidation{Valid: err == nil}
+		if config != nil {
+			report.Files, report.Profile = config.Files, config.Profile
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return failValidation(cmd, report, withCode(exitConfig, err))
+		}
+		if check, _ := cmd.Flags().GetBool("check"); check {
+			if err := checkCredentials(config); err != nil {
+				report.Valid = false
+				report.Errors = append(report.Errors, err.Error())
+				return failValidation(cmd, report, err)
+			}
+			report.CredentialsChecked = true
+		}
+		return render(cmd, configValidateTmpl, report)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd, configGetCmd, configSetCmd, configUnsetCmd, configValidateCmd)
+	configGetCmd.Flags().Bool("reveal", false, "print secrets without masking them")
+	addUserFlag(configSetCmd)
+	addSecretStoreFlag(configSetCmd, "")
+	addUserFlag(configUnsetCmd)
+	configValidateCmd.Flags().Bool("check", false, "exchange the refresh token to check the credentials are accepted")
+}
+
+// addUserFlag adds the --user flag to commands that write to the config
+func addUserFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("user", false, "write to the user config in $XDG_CONFIG_HOME/cws")
+}
+
+// writableConfigPath picks the config to write to, an explicit --config or
+// --user, then the project config if there is one, otherwise a new one is
+// created in the working directory
+func writableConfigPath(cmd *cobra.Command) string {
+	if path := getString(cmd, "config"); path != "" {
+		return path
+	} else if user, _ := cmd.Flags().GetBool("user"); user {
+		return gcloud.UserConfigPath()
+	} else if path := gcloud.FindConfig("."); path != "" {
+		return path
+	}
+	return gcloud.ConfigFileName
+}
+
+// profileKey scopes a key to a profile when one is selected
+func profileKey(profile, key string) string {
+	if profile == "" {
+		return key
+	}
+	return "profiles." + profile + "." + key
+}
+
+func editConfig(cmd *cobra.Command, edit func(*gcloud.ConfigFile, string) error, key string) error {
+	res := &result{ConfigPath: writableConfigPath(cmd)}
+	file, err := gcloud.OpenConfigFile(res.ConfigPath)
+	if err != nil {
+		return fail(cmd, res, withCode(exitConfig, err))
+	}
+	if err := edit(file, profileKey(configOptions(cmd).Profile, key)); err != nil {
+		return fail(cmd, res, withCode(exitConfig, err))
+	}
+	if err := file.Save(); err != nil {
+		return fail(cmd, res, err)
+	}
+	return render(cmd, `✅ {{"Config Saved At:" | green}} {{.ConfigPath | cyan}}`, res)
+}
+
+// checkCredentials exchanges the refresh token of the config, or of each
+// extension when it has several, to make sure google accepts them
+func checkCredentials(config *gcloud.Config) error {
+	if len(config.Extensions) == 0 {
+		_, err := authenticate(config, "")
+		return err
+	}
+	for _, ext := range config.Extensions {
+		if _, err := authenticate(config.ForExtension(ext), ext.Name); err != nil {
+			return fmt.Errorf("%v: %w", ext.Name, err)
+		}
+	}
+	return nil
+}
+
+// failValidation only renders the report for machine readable output, in text
+// mode the error is printed on exit
+func failValidation(cmd *cobra.Command, report configValidation, err error) error {
+	if outputFormat(cmd) != outputText {
+		render(cmd, "", report)
+	}
+	return err
+}
+
+func loadConfig(cmd *cobra.Command) (*gcloud.Config, error) {
+	config, err := gcloud.LoadConfig(configOptions(cmd))
+	if err == nil {
+		_, err = configBudget(config)
+	}
+	return config, withCode(exitConfig, err)
+}
+
+// loadSettings loads the config for commands that do not talk to the store,
+// so missing credentials are not an error
+func loadSettings(cmd *cobra.Command) (*gcloud.Config, error) {
+	config, err := gcloud.LoadConfig(configOptions(cmd))
+	if config == nil {
+		return nil, withCode(exitConfig, err)
+	}
+	return config, nil
+}
+
+func configOptions(cmd *cobra.Command) gcloud.LoadOptions {
+	return gcloud.LoadOptions{
+		Path:    getString(cmd, "config"),
+		Profile: getString(cmd, "profile"),
+		Secrets: secretStores(),
+	}
+}
+
+// maskSecret hides all but the last 4 characters of a secret, short secrets are
+// hidden completely
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", 8) + secret[len(secret)-4:]
+}
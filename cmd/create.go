@@ -5,43 +5,62 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/tanema/cws/lib/gcloud"
-	"github.com/tanema/cws/lib/term"
 )
 
 var createCmd = &cobra.Command{
 	Use:   "create [dir-path]",
 	Args:  cobra.ExactArgs(1),
 	Short: "create a new extension by uploading a brand new archive",
-	Run: func(cmd *cobra.Command, args []string) {
-		version := getVersion(cmd)
-		term.Println("🚚 Creating Version: {{. | bold}}", version)
-		client := authenticate(cmd)
-		archivePath := archiveExt(args[0], version, getString(cmd, "json"))
-		defer os.Remove(archivePath)
-		status, err := create(client, archivePath)
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		res := &result{}
+		if res.Version, err = getVersion(cmd); err != nil {
+			return fail(cmd, res, err)
+		}
+		info(cmd, "🚚 Creating Version: {{. | bold}}", res.Version)
+		config, err := loadConfig(cmd)
+		if err != nil {
+			return fail(cmd, res, err)
+		}
+		client, err := authenticate(config, res.Name)
 		if err != nil {
-			term.Println(`{{. | bold}}`, err)
-			return
-		}
-		term.Println(`✅ {{. | bold}} {{"Created Successfully" | green}}`, version)
-		term.Println(`ID: {{.ID}}
-Kind: {{.Kind}}
-State: {{.UploadState}}`, status)
-		term.Println("See package status at: {{. | blue}}", "https://chrome.google.com/webstore/devconsole")
+			return fail(cmd, res, err)
+		}
+		if err := archiveExt(res, args[0], getString(cmd, "json")); err != nil {
+			return fail(cmd, res, err)
+		}
+		defer os.Remove(res.ArchivePath)
+		if err := checkBudgets(cmd, config, res); err != nil {
+			return fail(cmd, res, err)
+		}
+		if isDryRun(cmd) {
+			planUpload(res, client.CreateRequest())
+			return render(cmd, dryRunTmpl, res)
+		}
+		if err := create(client, res); err != nil {
+			return fail(cmd, res, err)
+		}
+		if err := render(cmd, `✅ {{.Version | bold}} {{"Created Successfully" | green}}
+ID: {{.ItemID}}
+State: {{.UploadState}}`, res); err != nil {
+			return err
+		}
+		info(cmd, "See package status at: {{. | blue}}", "https://chrome.google.com/webstore/devconsole")
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(createCmd)
-	createCmd.Flags().StringP("config", "c", "./chrome_webstore.json", "id of extension to deploy")
 	createCmd.Flags().StringP("version", "v", "", "version to add to the manifest (default: yy.mm.dd.nn)")
 	createCmd.Flags().StringP("json", "j", "", "json changes to the manifest. Should be formatted by key:value comma separated")
 }
 
-func create(client *gcloud.Client, archivePath string) (status gcloud.WebStoreItem, err error) {
-	term.Spinner("Creating", func() error {
-		status, err = client.CreateExtension(archivePath)
+func create(client *gcloud.Client, res *result) error {
+	var item gcloud.WebStoreItem
+	err := spinner(res.Name, "Creating", func() (err error) {
+		item, err = client.CreateExtension(res.ArchivePath)
 		return err
 	})
-	return
+	res.ExtensionID, res.ItemID, res.UploadState = item.ID, item.ID, item.UploadState
+	return withCode(exitUpload, err)
 }
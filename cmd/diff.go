@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/diff"
+	"github.com/tanema/cws/lib/gcloud"
+)
+
+const diffTmpl = `{{"Comparing" | bold}} {{.A | cyan}} → {{.B | cyan}}
+{{- if not (or .Files .Manifest)}}
+{{"No differences" | green}}{{else}}
+{{"Files" | bold}} {{printf "+%v" .Added | green}} {{printf "-%v" .Removed | red}} {{printf "~%v" .Changed | yellow}}  {{size .SizeA}} → {{size .SizeB}} ({{if gt .Delta 0}}+{{end}}{{size .Delta}})
+{{- range .Files}}
+  {{if eq .Change "added"}}{{"+" | green}} {{printf "%-48v" .Path}} {{size .SizeB}}
+  {{- else if eq .Change "removed"}}{{"-" | red}} {{printf "%-48v" .Path}} {{size .SizeA}}
+  {{- else}}{{"~" | yellow}} {{printf "%-48v" .Path}} {{size .SizeA}} → {{size .SizeB}} ({{if gt .Delta 0}}+{{end}}{{size .Delta}}){{end}}
+{{- end}}
+{{- with .Manifest}}
+{{"Manifest" | bold}}{{range .}}
+  {{if eq .Change "added"}}{{"+" | green}} {{.Key}}: {{json .B}}
+  {{- else if eq .Change "removed"}}{{"-" | red}} {{.Key}}: {{json .A}}
+  {{- else}}{{"~" | yellow}} {{.Key}}: {{json .A | faint}} → {{json .B}}{{end}}
+{{- end}}{{end}}
+{{- with .Permissions}}
+{{"Permissions" | bold}}{{range .}}
+  {{if .Added}}{{printf "+ %v" .Permission | red | bold}}{{else}}{{printf "- %v" .Permission | green}}{{end}} {{.Field | faint}}{{with .Warning}} ⚠️  {{. | yellow}}{{end}}
+{{- end}}{{end}}
+{{- range .Files}}{{with .Diff}}
+{{range lines .}}
+{{if or (hasPrefix . "+++") (hasPrefix . "---")}}{{. | bold}}
+{{- else if hasPrefix . "@@"}}{{. | cyan}}
+{{- else if hasPrefix . "+"}}{{. | green}}
+{{- else if hasPrefix . "-"}}{{. | red}}
+{{- else}}{{.}}{{end}}
+{{- end}}{{end}}{{end}}
+{{- end}}`
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [a] [b]",
+	Args:  cobra.ExactArgs(2),
+	Short: "show what changed between two packages",
+	Long: `Diff compares two packages of an extension, the files that were added, removed
+or changed, the manifest and the permissions it asks for. Each package can be a
+directory, a zip or a crx, the version or sha256 prefix of a kept archive, or
+"published" for the kept archive of the published version.`,
+	Example: `  cws diff published dist
+  cws diff 1.4.0 1.5.0 --unified
+  cws diff old.crx new.zip --output json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loader := &packageLoader{cmd: cmd}
+		defer loader.cleanup()
+		a, err := loader.load(args[0])
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		b, err := loader.load(args[1])
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		unified, _ := cmd.Flags().GetBool("unified")
+		context, _ := cmd.Flags().GetInt("context")
+		report, err := diff.Compare(a, b, diff.Options{Unified: unified, Context: context})
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		return render(cmd, diffTmpl, report)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolP("unified", "u", false, "show a unified diff of each text file that changed")
+	diffCmd.Flags().IntP("context", "U", 3, "lines of context around each change in unified diffs")
+	diffCmd.Flags().String("extension", "", "name or id of the extension of kept archives (default: the extension in the config)")
+}
+
+// packageLoader reads the packages that are compared, the config is only
+// loaded when a package is a kept archive
+type packageLoader struct {
+	cmd    *cobra.Command
+	config *gcloud.Config
+	extID  string
+	temps  []string
+}
+
+func (loader *packageLoader) load(ref string) (*archive.Package, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return archive.Load(ref)
+	}
+	if loader.config == nil {
+		config, err := loadSettings(loader.cmd)
+		if err != nil {
+			return nil, err
+		}
+		if loader.extID, err = artifactExtension(loader.cmd, config); err != nil {
+			return nil, err
+		}
+		loader.config = extensionConfig(config, loader.extID)
+	}
+	store := artifactStore(loader.config)
+	var meta artifacts.Metadata
+	var err error
+	if ref == "published" {
+		meta, err = loader.published(store)
+	} else if meta, err = artifacts.Find(store, loader.extID, ref); errors.Is(err, artifacts.ErrNotFound) {
+		err = fmt.Errorf("%v is not a file, a directory or a kept archive: %w", ref, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "cws-diff-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	loader.temps = append(loader.temps, tmp.Name())
+	if err := store.Fetch(meta, tmp.Name()); err != nil {
+		return nil, err
+	}
+	pkg, err := archive.Load(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	pkg.Source = fmt.Sprintf("%v %v (%.12s)", meta.ExtensionID, meta.Version, meta.SHA256)
+	return pkg, nil
+}
+
+// published needs the credentials of the config, unlike the other packages
+func (loader *packageLoader) published(store artifacts.Store) (artifacts.Metadata, error) {
+	config, err := loadConfig(loader.cmd)
+	if err != nil {
+		return artifacts.Metadata{}, err
+	}
+	client, err := authenticate(extensionConfig(config, loader.extID), "")
+	if err != nil {
+		return artifacts.Metadata{}, err
+	}
+	return publishedArtifact(client, store, loader.extID, "")
+}
+
+func (loader *packageLoader) cleanup() {
+	for _, name := range loader.temps {
+		os.Remove(name)
+	}
+}
+
+// extensionConfig is the config of one of the configured extensions
+func extensionConfig(config *gcloud.Config, extID string) *gcloud.Config {
+	for _, ext := range config.Extensions {
+		if ext.ExtID == extID {
+			return config.ForExtension(ext)
+		}
+	}
+	return config
+}
+
+// publishedArtifact is the kept archive of the version that is published in
+// the store
+func publishedArtifact(client *gcloud.Client, store artifacts.Store, extID, name string) (artifacts.Metadata, error) {
+	itemStatus, err := status(client, name)
+	if err != nil {
+		return artifacts.Metadata{}, err
+	}
+	published := itemStatus.Published.CRXVersion
+	if published == "" {
+		return artifacts.Metadata{}, errors.New("no version has been published yet")
+	}
+	meta, err := artifacts.Find(store, extID, published)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return meta, fmt.Errorf("the published version %v was not uploaded with cws here so there is no archive of it: %w", published, err)
+	}
+	return meta, err
+}
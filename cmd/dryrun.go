@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/gcloud"
+)
+
+const dryRunTmpl = `🧪 {{"Dry Run" | yellow}} {{with .Version}}{{. | bold}} {{end}}no changes were made
+{{- with .Status}}
+  Draft Version    : {{or .Draft.CRXVersion "-" | bold}}
+  Published Version: {{or .Published.CRXVersion "-" | bold}}{{end}}
+{{- with .ArchivePath}}
+  Archive          : {{. | cyan}} ({{$.ArchiveSize}} bytes)
+  SHA256           : {{$.ArchiveHash}}{{end}}
+Requests that would be sent:{{range .DryRun}}
+  {{.Method | bold}} {{.URL | cyan}}{{with .PublishTarget}} target: {{. | bold}}{{end}}{{end}}`
+
+// plannedRequest is a request that would have been sent if not for --dry-run
+type plannedRequest struct {
+	gcloud.Request
+	ArchiveSize   int64  `json:"archive_size,omitempty"`
+	ArchiveHash   string `json:"archive_sha256,omitempty"`
+	PublishTarget string `json:"publish_target,omitempty"`
+}
+
+func isDryRun(cmd *cobra.Command) bool {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	return dryRun
+}
+
+// dryRunStatus fetches the item status so that a dry run still checks that the
+// credentials have access to the extension. Errors are classified by exitCode
+// like on a real run.
+func dryRunStatus(client *gcloud.Client, res *result) error {
+	itemStatus, err := status(client, res.Name)
+	if err != nil {
+		return err
+	}
+	res.Status = &itemStatus
+	return nil
+}
+
+func planUpload(res *result, req gcloud.Request) {
+	res.DryRun = append(res.DryRun, plannedRequest{
+		Request:     req,
+		ArchiveSize: res.ArchiveSize,
+		ArchiveHash: res.ArchiveHash,
+	})
+}
+
+func planPublish(res *result, req gcloud.Request, public bool) {
+	res.DryRun = append(res.DryRun, plannedRequest{
+		Request:       req,
+		PublishTarget: gcloud.PublishTarget(public),
+	})
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/hooks"
+	"github.com/tanema/cws/lib/manifest"
+	"github.com/tanema/cws/lib/term"
+)
+
+const (
+	errorTmpl     = `🔥 {{. | bold}}`
+	authErrorTmpl = `🔥 {{"Authentication Failed" | red}} {{.Err}}`
+	apiErrorTmpl  = `🔥 {{printf "(%v)%v" .Code .Status | yellow}} {{.Message | bold}}{{with .Reasons}} {{join ", " . | faint}}{{end}}`
+	uploadErrTmpl = `🔥 {{"Upload Failed" | red}} {{.State | yellow}}{{range .Errors}}
+  Code: {{.Code}}
+  Detail: {{.Detail}}{{end}}`
+	publishErrTmpl = `🔥 {{"Publish Failed" | red}} {{join ", " .Status | yellow}}{{range .Detail}}
+  {{.}}{{end}}`
+)
+
+// Exit codes returned by cws so that callers can branch on the result. These
+// are documented in the README and should not be renumbered.
+const (
+	exitOK         = 0
+	exitFailure    = 1 // unclassified errors and bad usage
+	exitConfig     = 2 // configuration is missing or malformed
+	exitAuth       = 3 // credentials were rejected
+	exitValidation = 4 // the version or manifest is not valid
+	exitUpload     = 5 // the webstore did not accept the upload
+	exitPublish    = 6 // the webstore rejected the publish
+	exitTimeout    = 7 // a request timed out
+	exitNetwork    = 8 // the api could not be reached
+	exitHook       = 9 // a hook failed or timed out
+)
+
+// cmdError tags an error with the exit code that cws should exit with
+type cmdError struct {
+	code int
+	err  error
+}
+
+func (err *cmdError) Error() string {
+	return err.err.Error()
+}
+
+func (err *cmdError) Unwrap() error {
+	return err.err
+}
+
+// withCode tags err with an exit code. Network failures and timeouts are left
+// untagged since they are not the fault of the step that failed.
+func withCode(code int, err error) error {
+	if err == nil || isTimeout(err) || isNetworkError(err) {
+		return err
+	}
+	return &cmdError{code: code, err: err}
+}
+
+// exitCode finds the exit code for an error, a code given with withCode takes
+// precedence over the type of the error
+func exitCode(err error) int {
+	var cmdErr *cmdError
+	var validationErr *manifest.ValidationError
+	var apiErr *gcloud.APIError
+	var hookErr *hooks.Error
+	if err == nil {
+		return exitOK
+	} else if errors.As(err, &cmdErr) {
+		return cmdErr.code
+	} else if errors.As(err, &hookErr) {
+		return exitHook
+	} else if isTimeout(err) {
+		return exitTimeout
+	} else if isNetworkError(err) {
+		return exitNetwork
+	} else if errors.As(err, &validationErr) {
+		return exitValidation
+	} else if errors.Is(err, &gcloud.AuthError{}) {
+		return exitAuth
+	} else if errors.Is(err, &gcloud.UploadError{}) {
+		return exitUpload
+	} else if errors.Is(err, &gcloud.PublishError{}) {
+		return exitPublish
+	} else if errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden) {
+		return exitAuth
+	}
+	return exitFailure
+}
+
+// isNetworkError is true for errors of reaching the api. Errors of files also
+// implement net.Error, so only the errors of requests and connections count.
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &urlErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (isNetworkError(err) && errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// printError renders the error to stderr, with more detail for errors from the api
+func printError(err error) {
+	var authErr *gcloud.AuthError
+	var apiErr *gcloud.APIError
+	var uploadErr *gcloud.UploadError
+	var publishErr *gcloud.PublishError
+	if errors.As(err, &uploadErr) {
+		term.Fprintln(os.Stderr, uploadErrTmpl, uploadErr)
+	} else if errors.As(err, &publishErr) {
+		term.Fprintln(os.Stderr, publishErrTmpl, publishErr)
+	} else if errors.As(err, &authErr) {
+		term.Fprintln(os.Stderr, authErrorTmpl, authErr)
+	} else if errors.As(err, &apiErr) {
+		term.Fprintln(os.Stderr, apiErrorTmpl, apiErr)
+	} else {
+		term.Fprintln(os.Stderr, errorTmpl, err)
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/hooks"
+	"github.com/tanema/cws/lib/manifest"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestExitCode(t *testing.T) {
+	_, pathErr := os.Stat("/nonexistent/cws")
+	refused := &url.Error{Op: "Post", URL: "https://chromewebstore.googleapis.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	timedOut := &url.Error{Op: "Post", URL: "https://chromewebstore.googleapis.com", Err: timeoutError{}}
+	cases := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, exitOK},
+		{"unclassified", errors.New("failed"), exitFailure},
+		{"path error", pathErr, exitFailure},
+		{"wrapped path error", fmt.Errorf("could not read config: %w", pathErr), exitFailure},
+		{"path error with code", withCode(exitConfig, pathErr), exitConfig},
+		{"wrapped code", fmt.Errorf("main: %w", withCode(exitValidation, errors.New("bad"))), exitValidation},
+		{"code over validation error", withCode(exitConfig, &manifest.ValidationError{Msg: "bad"}), exitConfig},
+		{"deadline", fmt.Errorf("upload: %w", context.DeadlineExceeded), exitTimeout},
+		{"request timeout", timedOut, exitTimeout},
+		{"request timeout in a step", withCode(exitUpload, timedOut), exitTimeout},
+		{"connection refused", refused, exitNetwork},
+		{"connection refused in a step", withCode(exitUpload, refused), exitNetwork},
+		{"dns", &net.DNSError{Err: "no such host", Name: "chromewebstore.googleapis.com"}, exitNetwork},
+		{"hook", withCode(exitHook, &hooks.Error{Hook: "prebuild", Err: errors.New("exit status 1")}), exitHook},
+		{"hook timeout", withCode(exitHook, &hooks.Error{Hook: "prebuild", Err: context.DeadlineExceeded, TimedOut: true}), exitHook},
+		{"validation", &manifest.ValidationError{Msg: "bad version"}, exitValidation},
+		{"auth", &gcloud.AuthError{Err: &gcloud.APIError{Code: 400}}, exitAuth},
+		{"unauthorized", &gcloud.APIError{Code: 401}, exitAuth},
+		{"forbidden", &gcloud.APIError{Code: 403}, exitAuth},
+		{"api", &gcloud.APIError{Code: 500}, exitFailure},
+		{"upload", &gcloud.UploadError{State: "FAILURE"}, exitUpload},
+		{"publish", &gcloud.PublishError{Status: []string{"ITEM_PENDING_REVIEW"}}, exitPublish},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, exitCode(tc.err))
+		})
+	}
+}
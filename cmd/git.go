@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// gitCommit is the commit checked out in dir, empty when dir is not in a git
+// repository
+func gitCommit(dir string) string {
+	return git(dir, "rev-parse", "HEAD")
+}
+
+// actor is who is running the release, the git user if there is one
+func actor(dir string) string {
+	if email := git(dir, "config", "user.email"); email != "" {
+		return email
+	} else if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+func git(dir string, args ...string) string {
+	if dir == "" {
+		dir = "."
+	}
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/ledger"
+	"github.com/tanema/cws/lib/term"
+)
+
+const historyTmpl = `{{if .Entries}}{{printf "%-20v %-16v %-16v %-8v %-8v %-7v %-8v %-8v %v" "Time" "Extension" "Version" "Action" "Target" "Result" "Commit" "SHA-256" "By" | bold}}
+{{- range .Entries}}
+{{printf "%-20v %-16v %-16v %-8v %-8v" (.Time.Local.Format "2006-01-02 15:04:05") (or .Name .ExtensionID) (or .Version "-") .Action (or .PublishTarget "-")}} {{if eq .Result "ok"}}{{printf "%-7v" .Result | green}}{{else}}{{printf "%-7v" .Result | red}}{{end}} {{printf "%-8v %-8v %v" (or (printf "%.8s" .Commit) "-") (or (printf "%.8s" .ArchiveSHA256) "-") (or .Actor "-")}}{{with .Error}} {{. | red}}{{end}}
+{{- end}}
+{{- else}}{{"No releases recorded in" | faint}} {{.Path | cyan}}{{end}}`
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Args:  cobra.NoArgs,
+	Short: "list the uploads, publishes and rollouts recorded in the release ledger, newest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilter(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		report := historyReport{Path: ledgerPath(config), Entries: []ledger.Entry{}}
+		entries, err := ledger.Read(report.Path)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		entries = filter.Apply(entries)
+		for i := len(entries) - 1; i >= 0; i-- {
+			report.Entries = append(report.Entries, entries[i])
+		}
+		return render(cmd, historyTmpl, report)
+	},
+}
+
+type historyReport struct {
+	Path    string         `json:"ledger"`
+	Entries []ledger.Entry `json:"entries"`
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().String("extension", "", "only show the extension with this name or id")
+	historyCmd.Flags().String("version", "", "only show this version")
+	historyCmd.Flags().String("action", "", "only show upload, publish or rollout")
+	historyCmd.Flags().String("result", "", "only show ok or failed")
+	historyCmd.Flags().String("since", "", "only show releases since a date like 2024-01-02 or for a duration like 72h")
+	historyCmd.Flags().Int("limit", 20, "how many releases to show, 0 for all")
+}
+
+func historyFilter(cmd *cobra.Command) (ledger.Filter, error) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	filter := ledger.Filter{
+		Extension: getString(cmd, "extension"),
+		Version:   getString(cmd, "version"),
+		Action:    getString(cmd, "action"),
+		Result:    getString(cmd, "result"),
+		Limit:     limit,
+	}
+	since := getString(cmd, "since")
+	if since == "" {
+		return filter, nil
+	} else if ago, err := time.ParseDuration(since); err == nil {
+		filter.Since = time.Now().Add(-ago)
+		return filter, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			filter.Since = date
+			return filter, nil
+		}
+	}
+	return filter, fmt.Errorf("invalid --since %q, expected a date like 2024-01-02 or a duration like 72h", since)
+}
+
+// ledgerPath is the ledger of the config, or the default one in the user state
+func ledgerPath(config *gcloud.Config) string {
+	if config.Ledger != "" {
+		return config.Ledger
+	}
+	return ledger.DefaultPath()
+}
+
+// recordRelease appends an action on the target to the release ledger. Like
+// notifications, a failure to record only prints a warning.
+func recordRelease(t target, res *result, action string, cause error) {
+	entry := ledger.Entry{
+		Action:        action,
+		Name:          res.Name,
+		ExtensionID:   res.ExtensionID,
+		Version:       res.Version,
+		Commit:        res.Commit,
+		ArchiveSHA256: res.ArchiveHash,
+		Actor:         actor(t.source),
+		Result:        ledger.ResultOK,
+		State:         releaseState(res, action == ledger.ActionUpload),
+	}
+	if action != ledger.ActionUpload {
+		entry.PublishTarget = gcloud.PublishTarget(t.public)
+		entry.DeployPercentage = res.Rollout
+	}
+	if cause != nil {
+		entry.Result = ledger.ResultFailed
+		entry.Error = t.config.Redact(cause.Error())
+	}
+	if err := ledger.Append(ledgerPath(t.config), entry); err != nil {
+		term.Println(`⚠️  {{. | yellow}}`, fmt.Sprintf("could not record the release: %v", err))
+	}
+}
+
+// releaseState is the upload state of an upload, or the publish status
+func releaseState(res *result, upload bool) string {
+	if upload {
+		return res.UploadState
+	}
+	return strings.Join(res.PublishStatus, ",")
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/hooks"
+	"github.com/tanema/cws/lib/term"
+)
+
+// runHook runs a hook of the target with the state of the release in its
+// environment. On a dry run only the hooks that build the archive run. Hook
+// output goes to stderr since stdout is kept for results.
+func runHook(cmd *cobra.Command, t target, res *result, hook string, cause error) error {
+	timeout, _ := t.config.Hooks.TimeoutDuration()
+	runner := hooks.Runner{Commands: t.config.Hooks.Commands(), Timeout: timeout, Stdout: os.Stderr, Stderr: os.Stderr}
+	if !runner.Has(hook) || (isDryRun(cmd) && hook != hooks.Prebuild && hook != hooks.Postarchive) {
+		return nil
+	}
+	info(cmd, "🪝 {{with .Name}}[{{.}}] {{end}}Running {{.Hook | bold}} hook", struct{ Name, Hook string }{res.Name, hook})
+	return withCode(exitHook, runner.Run(hook, hookEnv(cmd, t, res, cause)))
+}
+
+// runFailureHook runs onfailure, a failure of the hook itself is only reported
+// so that the exit code is still that of the step that failed
+func runFailureHook(cmd *cobra.Command, t target, res *result, cause error) {
+	if err := runHook(cmd, t, res, hooks.OnFailure, cause); err != nil {
+		term.Println(`⚠️  {{. | yellow}}`, err.Error())
+	}
+}
+
+func hookEnv(cmd *cobra.Command, t target, res *result, cause error) map[string]string {
+	env := map[string]string{
+		"CWS_DRY_RUN":        strconv.FormatBool(isDryRun(cmd)),
+		"CWS_PUBLISH_TARGET": gcloud.PublishTarget(t.public),
+	}
+	values := map[string]string{
+		"CWS_EXTENSION_NAME": res.Name,
+		"CWS_EXTENSION_ID":   res.ExtensionID,
+		"CWS_VERSION":        res.Version,
+		"CWS_SOURCE_DIR":     t.source,
+		"CWS_ARCHIVE_PATH":   res.ArchivePath,
+		"CWS_ARCHIVE_SHA256": res.ArchiveHash,
+		"CWS_UPLOAD_STATE":   res.UploadState,
+		"CWS_PUBLISH_STATUS": strings.Join(res.PublishStatus, ","),
+	}
+	if res.ArchiveSize > 0 {
+		values["CWS_ARCHIVE_SIZE"] = strconv.FormatInt(res.ArchiveSize, 10)
+	}
+	if cause != nil {
+		values["CWS_ERROR"] = cause.Error()
+	}
+	// unset values are left out so that they do not clear the environment
+	for key, value := range values {
+		if value != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/lint"
+)
+
+var i18nCmd = &cobra.Command{
+	Use:   "i18n",
+	Short: "check the translations in _locales",
+}
+
+var i18nCheckCmd = &cobra.Command{
+	Use:   "check [path]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "check that every locale is valid json, translates the default locale and fits the store limits",
+	Long: `Check parses every _locales/*/messages.json and compares it to the messages of
+default_locale. Invalid json, undefined or mismatched placeholders and a
+localized name or description over the store limits are errors. Missing,
+stale and unused messages are warnings, use --strict to fail on them too.
+Findings are left out with lint.suppress in the config like for cws lint.
+Without a path the sources of the configured extensions are checked, pick
+them with --only or --all. Failures exit with code 4.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		sources, err := lintSources(cmd, config, args)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		strict, _ := cmd.Flags().GetBool("strict")
+		reports := []lintReport{}
+		errCount, warnCount := 0, 0
+		for _, src := range sources {
+			pkg, err := archive.Load(src.path)
+			if err != nil {
+				return fail(cmd, &result{Name: src.name}, err)
+			}
+			report := lintReport{Name: src.name, Report: lint.CheckI18n(pkg)}
+			if err := report.Suppress(config.Lint.Suppress); err != nil {
+				return fail(cmd, &result{Name: src.name}, withCode(exitConfig, err))
+			}
+			errCount += report.Errors
+			warnCount += report.Warnings
+			reports = append(reports, report)
+		}
+		if err := renderLint(cmd, reports); err != nil {
+			return err
+		} else if errCount > 0 {
+			return withCode(exitValidation, fmt.Errorf("i18n check found %v errors", errCount))
+		} else if strict && warnCount > 0 {
+			return withCode(exitValidation, fmt.Errorf("i18n check found %v warnings", warnCount))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(i18nCmd)
+	i18nCmd.AddCommand(i18nCheckCmd)
+	addTargetFlags(i18nCheckCmd)
+	i18nCheckCmd.Flags().Bool("strict", false, "fail on warnings like missing or unused messages too")
+}
@@ -1,42 +1,80 @@
 package cmd
 
 import (
-	"encoding/json"
-	"os"
-
 	"github.com/spf13/cobra"
 	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/secrets"
 	"github.com/tanema/cws/lib/term"
 )
 
 var initCmd = &cobra.Command{
 	Use:   "init [client-id] [client-secret]",
 	Args:  cobra.ExactArgs(2),
-	Short: "A brief description of your command",
-	Run: func(cmd *cobra.Command, args []string) {
-		auth := gcloud.NewAuthenticator(args[0], args[1], "https://www.googleapis.com/auth/chromewebstore")
+	Short: "authorize cws with an oauth client and save the credentials to the config",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		res := &result{ConfigPath: writableConfigPath(cmd)}
+		// the existing config is only loaded for its network settings, the
+		// config and profile are created when they do not exist yet
+		opts := configOptions(cmd)
+		opts.AllowMissing = true
+		conf, err := gcloud.LoadConfig(opts)
+		if conf == nil {
+			return fail(cmd, res, withCode(exitConfig, err))
+		}
+		conf.ID, conf.Secret = args[0], args[1]
+		auth := gcloud.NewAuthenticator(conf, "https://www.googleapis.com/auth/chromewebstore")
 		term.Println(`Please visit this url to start oauth flow.
 
 {{. | blue}}
 
 `, auth.URL())
-		var conf *gcloud.Config
-		var err error
-		cobra.CheckErr(term.Spinner("Waiting for response", func() error {
+		if err = term.Spinner("Waiting for response", func() error {
 			conf, err = auth.ListForResponse()
 			return err
-		}))
+		}); err != nil {
+			return fail(cmd, res, withCode(exitAuth, err))
+		}
 
-		cobra.CheckErr(term.Spinner("Saving config", func() error {
-			confBytes, err := json.MarshalIndent(conf, "", "\t")
-			if err != nil {
-				return err
-			}
-			return os.WriteFile("chrome_webstore.json", confBytes, 0666)
-		}))
+		if err := saveCredentials(cmd, res.ConfigPath, configOptions(cmd).Profile, conf); err != nil {
+			return fail(cmd, res, err)
+		}
+		return render(cmd, `✅ {{"Config Saved At:" | green}} {{.ConfigPath | cyan}}`, res)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(initCmd)
+	addUserFlag(initCmd)
+	addSecretStoreFlag(initCmd, secrets.KindKeyring)
+}
+
+// saveCredentials writes the credentials into the config at path, keeping any
+// other values already in it. With a profile, they are saved to that profile.
+// The client secret and refresh token go to the secret store and the config
+// only references them. Secrets are saved before the spinner starts as the age
+// store may prompt for a passphrase.
+func saveCredentials(cmd *cobra.Command, path, profile string, conf *gcloud.Config) error {
+	values := map[string]string{profileKey(profile, "client_id"): conf.ID}
+	for key, secret := range map[string]string{
+		"client_secret": conf.Secret,
+		"refresh_token": conf.RefreshToken,
+	} {
+		value, err := saveSecret(cmd, profile, key, secret)
+		if err != nil {
+			return err
+		}
+		values[profileKey(profile, key)] = value
+	}
+	return term.Spinner("Saving config", func() error {
+		file, err := gcloud.OpenConfigFile(path)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			if err := file.Set(key, value); err != nil {
+				return err
+			}
+		}
+		return file.Save()
+	})
 }
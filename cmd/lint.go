@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/lint"
+	"github.com/tanema/cws/lib/term"
+)
+
+const lintTmpl = `{{if .Findings}}🔎 {{with .Name}}[{{.}}] {{end}}{{.Source | cyan}}
+{{printf "%-8v %-20v %-32v %v" "Severity" "Rule" "Path" "Message" | bold}}
+{{- range .Findings}}
+{{if eq .Severity "error"}}{{printf "%-8v" .Severity | red}}{{else}}{{printf "%-8v" .Severity | yellow}}{{end}} {{printf "%-20v %-32v" .Rule (or .Path "-")}} {{.Message}}
+{{- end}}
+{{.Errors}} errors, {{.Warnings}} warnings{{with .Suppressed}}, {{.}} suppressed{{end}}
+{{- else}}✅ {{with .Name}}[{{.}}] {{end}}{{"No problems found in" | green}} {{.Source | cyan}}{{with .Suppressed}} {{printf "(%v suppressed)" . | faint}}{{end}}{{end}}`
+
+type (
+	lintReport struct {
+		Name string `json:"name,omitempty"`
+		*lint.Report
+	}
+	lintSource struct {
+		name, path string
+	}
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [path]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "check the files the manifest refers to, icons, locales, the content security policy and scripts",
+	Long: `Lint checks a directory, zip or crx the way it would be archived, so files that
+are excluded by .cwsignore count as missing. The content security policy and
+the scripts of the package are checked for code that is run from strings or
+loaded from other hosts, which the store rejects. Findings are left out with
+lint.suppress in the config, by rule like "eval" or by rule and path like
+"eval:vendor/*". Without a path the sources of the configured extensions are
+checked, pick them with --only or --all. Any error exits with code 4.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadSettings(cmd)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		sources, err := lintSources(cmd, config, args)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		reports := []lintReport{}
+		errCount := 0
+		for _, src := range sources {
+			pkg, err := archive.Load(src.path)
+			if err != nil {
+				return fail(cmd, &result{Name: src.name}, err)
+			}
+			report := lintReport{Name: src.name, Report: lint.Check(pkg)}
+			if err := report.Suppress(config.Lint.Suppress); err != nil {
+				return fail(cmd, &result{Name: src.name}, withCode(exitConfig, err))
+			}
+			errCount += report.Errors
+			reports = append(reports, report)
+		}
+		if err := renderLint(cmd, reports); err != nil {
+			return err
+		} else if errCount > 0 {
+			return withCode(exitValidation, fmt.Errorf("lint found %v errors", errCount))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	addTargetFlags(lintCmd)
+}
+
+// lintSources is the path that was given, or the sources of the configured
+// extensions picked by --only or --all
+func lintSources(cmd *cobra.Command, config *gcloud.Config, args []string) ([]lintSource, error) {
+	if len(args) > 0 {
+		return []lintSource{{path: args[0]}}, nil
+	}
+	extensions, err := selectExtensions(cmd, config)
+	if err != nil {
+		return nil, err
+	}
+	sources := []lintSource{}
+	for _, ext := range extensions {
+		if ext.Source != "" {
+			sources = append(sources, lintSource{name: ext.Name, path: ext.Source})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no extension source is configured, pass the directory, zip or crx to lint")
+	}
+	return sources, nil
+}
+
+func renderLint(cmd *cobra.Command, reports []lintReport) error {
+	if outputFormat(cmd) != outputText {
+		if len(reports) == 1 {
+			return render(cmd, "", reports[0])
+		}
+		return render(cmd, "", struct {
+			Results []lintReport `json:"results"`
+		}{reports})
+	}
+	for _, report := range reports {
+		if err := term.Fprintln(os.Stdout, lintTmpl, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/gcloud"
+)
+
+// logLevel is shared so that a config with debug enabled can lower it after
+// the logger is set up
+var logLevel = new(slog.LevelVar)
+
+// recording collects the api exchanges for --har, it is written to harPath
+// when the command exits. cassette records them for replaying in tests.
+var (
+	recording *gcloud.HAR
+	harPath   string
+	cassette  *gcloud.Cassette
+)
+
+func addLogFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("log-level", "warn", "log level: debug, info, warn or error, debug logs every api request")
+	cmd.PersistentFlags().String("log-format", "text", "log format: text or json")
+	cmd.PersistentFlags().String("har", "", "write every api request and response, with secrets redacted, to a HAR file")
+	cmd.PersistentFlags().String("record-cassette", "", "record the api exchanges to a cassette for the lib/gcloud tests")
+	cmd.PersistentFlags().MarkHidden("record-cassette")
+}
+
+// setupLogging configures the default logger on stderr from the flags,
+// CWS_DEBUG is a shorthand for --log-level debug
+func setupLogging(cmd *cobra.Command) error {
+	if err := logLevel.UnmarshalText([]byte(getString(cmd, "log-level"))); err != nil {
+		return fmt.Errorf("invalid --log-level: %w", err)
+	}
+	if debug, _ := strconv.ParseBool(os.Getenv("CWS_DEBUG")); debug {
+		logLevel.Set(slog.LevelDebug)
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	switch format := getString(cmd, "log-format"); format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return fmt.Errorf("invalid --log-format %q, expected text or json", format)
+	}
+	if harPath = getString(cmd, "har"); harPath != "" {
+		recording = &gcloud.HAR{}
+		gcloud.SetHAR(recording)
+	}
+	if path := getString(cmd, "record-cassette"); path != "" {
+		cassette, _ = gcloud.NewCassette(path, gcloud.Record)
+		gcloud.SetTransport(cassette)
+	}
+	return nil
+}
+
+// debugConfig lowers the log level for a config with debug enabled
+func debugConfig(config *gcloud.Config) {
+	if config.Debug && logLevel.Level() > slog.LevelDebug {
+		logLevel.Set(slog.LevelDebug)
+	}
+}
+
+// saveRecordings writes the recorded exchanges, it runs even if the command
+// failed since that is when they are most useful
+func saveRecordings() {
+	if recording != nil {
+		if err := recording.WriteFile(harPath); err != nil {
+			slog.Error("could not write the HAR file", "path", harPath, "error", err)
+		}
+	}
+	if cassette != nil {
+		if err := cassette.Save(); err != nil {
+			slog.Error("could not write the cassette", "path", cassette.Path, "error", err)
+		}
+	}
+}
@@ -13,8 +13,15 @@ var manifestCmd = &cobra.Command{
 	Use:   "manifest [dir-path]",
 	Args:  cobra.ExactArgs(1),
 	Short: "Update the manifest version, and remove any dev keys",
-	Run: func(cmd *cobra.Command, args []string) {
-		update_manifest(args[0], getVersion(cmd), getString(cmd, "json"))
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		res := &result{ManifestPath: filepath.Join(args[0], "manifest.json")}
+		if res.Version, err = getVersion(cmd); err != nil {
+			return fail(cmd, res, err)
+		}
+		if err := update_manifest(res.ManifestPath, res.Version, getString(cmd, "json")); err != nil {
+			return fail(cmd, res, err)
+		}
+		return render(cmd, `✅ {{.Version | bold}} {{"Manifest Updated:" | green}} {{.ManifestPath | cyan}}`, res)
 	},
 }
 
@@ -24,8 +31,8 @@ func init() {
 	manifestCmd.Flags().StringP("json", "j", "", "json changes to the manifest. Should be formatted by key:value comma separated")
 }
 
-func update_manifest(path, version, jsonChangeset string) {
-	cobra.CheckErr(term.Spinner(fmt.Sprintf("Updating manifest version to %v", version), func() error {
-		return manifest.Update(filepath.Join(path, "manifest.json"), version, jsonChangeset)
-	}))
+func update_manifest(path, version, jsonChangeset string) error {
+	return term.Spinner(fmt.Sprintf("Updating manifest version to %v", version), func() error {
+		return manifest.Update(path, version, jsonChangeset)
+	})
 }
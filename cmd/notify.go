@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/notify"
+	"github.com/tanema/cws/lib/term"
+)
+
+// notifyRelease sends an event about the target to the configured notifiers.
+// A failed step is only sent, as a rejection, when the store turned it down.
+// Notifications are best effort so a failure to send is only reported.
+func notifyRelease(t target, res *result, event string, cause error) {
+	var uploadErr *gcloud.UploadError
+	var publishErr *gcloud.PublishError
+	if len(t.config.Notifiers) == 0 {
+		return
+	} else if cause != nil && !errors.As(cause, &uploadErr) && !errors.As(cause, &publishErr) {
+		return
+	}
+	ev := notify.Event{
+		Event:            event,
+		Name:             res.Name,
+		ExtensionID:      res.ExtensionID,
+		Version:          res.Version,
+		State:            releaseState(res, event == notify.EventUpload),
+		PublishTarget:    gcloud.PublishTarget(t.public),
+		DeployPercentage: res.Rollout,
+	}
+	if cause != nil {
+		ev.Event = notify.EventRejected
+		ev.Errors = []string{cause.Error()}
+	}
+	client, err := t.config.HTTPClient()
+	if err == nil {
+		err = notify.Sender{Client: client}.Send(t.config.Notifiers, ev)
+	}
+	if err != nil {
+		term.Println(`⚠️  {{. | yellow}}`, t.config.Redact(err.Error()))
+	}
+}
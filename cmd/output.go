@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/manifest"
+	"github.com/tanema/cws/lib/term"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// result is the structured outcome of a command, it is what gets emitted on
+// stdout when running with --output json or yaml
+type result struct {
+	Name          string                      `json:"name,omitempty"`
+	ConfigPath    string                      `json:"config_path,omitempty"`
+	ExtensionID   string                      `json:"extension_id,omitempty"`
+	Version       string                      `json:"version,omitempty"`
+	ManifestPath  string                      `json:"manifest_path,omitempty"`
+	ArchivePath   string                      `json:"archive_path,omitempty"`
+	ArchiveHash   string                      `json:"archive_sha256,omitempty"`
+	ArchiveSize   int64                       `json:"archive_size,omitempty"`
+	Sizes         *archive.Sizes              `json:"sizes,omitempty"`
+	OverBudget    []archive.Violation         `json:"budget_violations,omitempty"`
+	Commit        string                      `json:"commit,omitempty"`
+	ItemID        string                      `json:"item_id,omitempty"`
+	UploadState   string                      `json:"upload_state,omitempty"`
+	ItemErrors    []gcloud.WebStoreItemError  `json:"item_errors,omitempty"`
+	PublishStatus []string                    `json:"publish_status,omitempty"`
+	PublishDetail []string                    `json:"publish_detail,omitempty"`
+	Permissions   []manifest.PermissionChange `json:"permission_changes,omitempty"`
+	Rollout       int                         `json:"deploy_percentage,omitempty"`
+	RollbackFrom  string                      `json:"rollback_from,omitempty"`
+	Status        *gcloud.WebStoreItemStatus  `json:"status,omitempty"`
+	DryRun        []plannedRequest            `json:"dry_run,omitempty"`
+	Errors        []string                    `json:"errors,omitempty"`
+}
+
+func validateOutput(cmd *cobra.Command) error {
+	switch format := outputFormat(cmd); format {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of text, json, yaml", format)
+	}
+}
+
+func outputFormat(cmd *cobra.Command) string {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return outputText
+	}
+	return format
+}
+
+// render will write the data to stdout in the requested output format, the
+// template is only used for text output
+func render(cmd *cobra.Command, tmpl string, data interface{}) error {
+	switch outputFormat(cmd) {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case outputYAML:
+		// round trip through json so that yaml output uses the same field names
+		jsonBytes, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(jsonBytes, &generic); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(os.Stdout)
+		defer enc.Close()
+		return enc.Encode(generic)
+	default:
+		return term.Fprintln(os.Stdout, tmpl, data)
+	}
+}
+
+// info prints human oriented messages to stderr, they are dropped when the
+// output is meant to be consumed by another program
+func info(cmd *cobra.Command, tmpl string, data interface{}) {
+	if outputFormat(cmd) == outputText {
+		term.Println(tmpl, data)
+	}
+}
+
+// fail records the error on the result so that machine readable output still
+// gets a result, the error itself is reported by Execute
+func fail(cmd *cobra.Command, res *result, err error) error {
+	recordError(res, err)
+	if outputFormat(cmd) != outputText {
+		render(cmd, "", res)
+	}
+	return err
+}
+
+func recordError(res *result, err error) {
+	res.Errors = append(res.Errors, err.Error())
+	var uploadErr *gcloud.UploadError
+	if errors.As(err, &uploadErr) {
+		res.ItemErrors = uploadErr.Errors
+	}
+}
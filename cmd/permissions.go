@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/manifest"
+)
+
+const permissionsTmpl = `⚠️  {{with .Name}}[{{.}}] {{end}}{{"Permissions changed since" | yellow}} {{.Baseline | bold}}
+{{- range .Changes}}
+  {{if .Added}}{{printf "+ %v" .Permission | bold}}{{else}}{{printf "- %v" .Permission}}{{end}} {{.Field | faint}}
+  {{- if .Warning}} {{"warns:" | red}} {{.Warning}}{{else if .Added}} {{"no warning" | faint}}{{end}}
+{{- end}}`
+
+type permissionReport struct {
+	Name     string
+	Baseline string
+	Changes  []manifest.PermissionChange
+}
+
+func addPermissionFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("allow-permission-changes", false, "release even when the manifest asks for permissions that the last release did not")
+	cmd.Flags().Bool("no-permission-baseline", false, "release without comparing permissions when there is no kept archive of an earlier release")
+}
+
+// checkPermissions compares the permissions of the new archive with the kept
+// archive of the published version, or the newest kept archive below the new
+// version when the published one was not kept. Chrome disables an extension
+// until users accept the warnings of new permissions, and new permissions can
+// send it back to review, so adding any needs --allow-permission-changes.
+// Without a kept archive to compare with, like on a CI runner that starts with
+// an empty cache, a release of a published extension needs one of the flags.
+func checkPermissions(cmd *cobra.Command, t target, client *gcloud.Client, res *result) error {
+	store := artifactStore(t.config)
+	baseline, published, err := permissionBaseline(client, store, res)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		allow, _ := cmd.Flags().GetBool("allow-permission-changes")
+		noBaseline, _ := cmd.Flags().GetBool("no-permission-baseline")
+		if published != "" && !allow && !noBaseline {
+			return withCode(exitValidation, fmt.Errorf("there is no kept archive of %v or an earlier version to compare permissions with, keep artifacts.dir between runs or pass --no-permission-baseline to release anyway", published))
+		}
+		info(cmd, "{{with .Name}}[{{.}}] {{end}}{{\"No earlier archive to compare permissions with\" | faint}}", res)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	oldManifest, err := artifactManifest(store, baseline)
+	if err != nil {
+		return err
+	}
+	newManifest, err := archive.ReadManifest(res.ArchivePath)
+	if err != nil {
+		return err
+	}
+	oldPerms, err := manifest.ParsePermissions(oldManifest)
+	if err != nil {
+		return err
+	}
+	newPerms, err := manifest.ParsePermissions(newManifest)
+	if err != nil {
+		return err
+	}
+	res.Permissions = manifest.DiffPermissions(oldPerms, newPerms)
+	if len(res.Permissions) == 0 {
+		return nil
+	}
+	info(cmd, permissionsTmpl, permissionReport{Name: res.Name, Baseline: baseline.Version, Changes: res.Permissions})
+
+	added, warned := 0, 0
+	for _, change := range res.Permissions {
+		if change.Added {
+			added++
+		}
+		if change.Warning != "" {
+			warned++
+		}
+	}
+	if allow, _ := cmd.Flags().GetBool("allow-permission-changes"); allow || added == 0 {
+		return nil
+	} else if warned > 0 {
+		return withCode(exitValidation, fmt.Errorf("%v added permissions show a warning, chrome disables the extension until users accept it, pass --allow-permission-changes to release anyway", warned))
+	}
+	return withCode(exitValidation, fmt.Errorf("%v permissions were added since %v, which can send the extension back to review, pass --allow-permission-changes to release anyway", added, baseline.Version))
+}
+
+// permissionBaseline is the kept archive that the new archive replaces, and the
+// version that is published, empty when nothing was published yet. The status
+// of a dry run is reused.
+func permissionBaseline(client *gcloud.Client, store artifacts.Store, res *result) (artifacts.Metadata, string, error) {
+	itemStatus := res.Status
+	if itemStatus == nil {
+		fetched, err := status(client, res.Name)
+		if err != nil {
+			return artifacts.Metadata{}, "", err
+		}
+		itemStatus = &fetched
+	}
+	published := itemStatus.Published.CRXVersion
+	if published != "" {
+		meta, err := artifacts.Find(store, res.ExtensionID, published)
+		if !errors.Is(err, artifacts.ErrNotFound) {
+			return meta, published, err
+		}
+	}
+	meta, err := artifacts.Latest(store, res.ExtensionID, res.Version)
+	return meta, published, err
+}
+
+// artifactManifest is the manifest kept in the metadata, or read from the
+// archive for artifacts kept without it
+func artifactManifest(store artifacts.Store, meta artifacts.Metadata) ([]byte, error) {
+	if len(meta.Manifest) > 0 {
+		return meta.Manifest, nil
+	}
+	tmp, err := os.CreateTemp("", "cws-artifact-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := store.Fetch(meta, tmp.Name()); err != nil {
+		return nil, err
+	}
+	return archive.ReadManifest(tmp.Name())
+}
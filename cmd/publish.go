@@ -6,39 +6,67 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/tanema/cws/lib/gcloud"
-	"github.com/tanema/cws/lib/term"
+	"github.com/tanema/cws/lib/hooks"
+	"github.com/tanema/cws/lib/ledger"
+	"github.com/tanema/cws/lib/notify"
 )
 
 var publishCmd = &cobra.Command{
 	Use:   "publish",
 	Short: "publish the extension to the chrome webstore",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("🚚 Publishing")
-		test, _ := cmd.Flags().GetBool("test")
-		status, err := publish(authenticate(cmd), !test)
-		if err != nil {
-			term.Println(`{{. | bold}}`, err)
-			return
-		}
-		term.Println(`✅ {{"Publish Successfully" | green}} Publication Status: {{. | cyan}}`, status.Status)
-		term.Println("See package status at: {{. | blue}}", "https://chrome.google.com/webstore/devconsole")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTargets(cmd, nil, `✅ {{"Publish Successfully" | green}} Publication Status: {{.PublishStatus | join ", " | cyan}}`, publishTarget)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(publishCmd)
-	publishCmd.Flags().StringP("config", "c", "./chrome_webstore.json", "id of extension to deploy")
 	publishCmd.Flags().BoolP("test", "t", false, "Deploy to test users, otherwise default")
+	addTargetFlags(publishCmd)
+}
+
+func publishTarget(cmd *cobra.Command, t target, res *result) error {
+	info(cmd, "🚚 {{with .Name}}[{{.}}] {{end}}Publishing", res)
+	client, err := authenticate(t.config, res.Name)
+	if err != nil {
+		return err
+	}
+	if isDryRun(cmd) {
+		if err := dryRunStatus(client, res); err != nil {
+			return err
+		}
+		planPublish(res, client.PublishRequest(t.public), t.public)
+		return nil
+	}
+	return publishWithHooks(cmd, t, client, res)
+}
+
+// publishWithHooks records and notifies about the result of the publish and
+// runs the postpublish hook after a successful one
+func publishWithHooks(cmd *cobra.Command, t target, client *gcloud.Client, res *result) error {
+	err := publish(client, res, t.public)
+	recordRelease(t, res, ledger.ActionPublish, err)
+	notifyRelease(t, res, notify.EventPublish, err)
+	if err != nil {
+		return err
+	} else if res.Rollout > 0 {
+		recordRelease(t, res, ledger.ActionRollout, nil)
+		notifyRelease(t, res, notify.EventRollout, nil)
+	}
+	return runHook(cmd, t, res, hooks.Postpublish, nil)
 }
 
-func publish(client *gcloud.Client, public bool) (status gcloud.WebStoreItem, err error) {
+func publish(client *gcloud.Client, res *result, public bool) error {
 	audience := ""
 	if !public {
 		audience = "to test users"
 	}
-	term.Spinner(fmt.Sprintf("Publishing %v", audience), func() error {
+	var status gcloud.WebStoreItem
+	err := spinner(res.Name, fmt.Sprintf("Publishing %v", audience), func() (err error) {
 		status, err = client.PublishExtension(public)
 		return err
 	})
-	return
+	res.PublishStatus, res.PublishDetail = status.Status, status.Detail
+	res.Rollout = status.DeployPercentage
+	return withCode(exitPublish, err)
 }
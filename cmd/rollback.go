@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/manifest"
+	"github.com/tanema/cws/lib/term"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Args:  cobra.NoArgs,
+	Short: "publish a previously uploaded archive again, with a version above the published one",
+	Long: `Rollback finds the archive of an earlier version in the artifact store, sets
+its manifest version to one above the published version, and uploads and
+publishes it. Without --to the highest kept version below the published one is
+used. Only archives that were uploaded by cws on this machine, or to the
+configured artifacts dir, can be rolled back to.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTargets(cmd, nil, `✅ {{"Rolled Back" | green}} to {{.RollbackFrom | bold}} as {{.Version | bold}} Publication Status: {{.PublishStatus | join ", " | cyan}}`, rollbackTarget)
+	},
+}
+
+// confirmMu keeps the prompts of several extensions from overlapping
+var confirmMu sync.Mutex
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().String("to", "", "version or sha256 prefix of the archive to roll back to (default: the highest version below the published one)")
+	rollbackCmd.Flags().BoolP("test", "t", false, "Deploy to test users, otherwise default")
+	rollbackCmd.Flags().BoolP("yes", "y", false, "roll back without asking for confirmation")
+	addTargetFlags(rollbackCmd)
+}
+
+func rollbackTarget(cmd *cobra.Command, t target, res *result) error {
+	client, err := authenticate(t.config, res.Name)
+	if err != nil {
+		return err
+	}
+	itemStatus, err := status(client, res.Name)
+	if err != nil {
+		return err
+	}
+	res.Status = &itemStatus
+	published := itemStatus.Published.CRXVersion
+	if published == "" {
+		return errors.New("no version has been published yet so there is nothing to roll back")
+	}
+	store := artifactStore(t.config)
+	meta, err := rollbackArtifact(store, res.ExtensionID, published, getString(cmd, "to"))
+	if err != nil {
+		return err
+	} else if res.Version, err = manifest.NextVersion(published); err != nil {
+		return err
+	}
+	res.RollbackFrom, res.Commit = meta.Version, meta.Commit
+	info(cmd, "⏪ {{with .Name}}[{{.}}] {{end}}Rolling back to {{.RollbackFrom | bold}} as version {{.Version | bold}}", res)
+	if err := rewriteArtifact(res, store, meta); err != nil {
+		return err
+	}
+	defer os.Remove(res.ArchivePath)
+
+	if isDryRun(cmd) {
+		planUpload(res, client.UploadRequest())
+		planPublish(res, client.PublishRequest(t.public), t.public)
+		return nil
+	} else if err := confirmRollback(cmd, res, published); err != nil {
+		return err
+	} else if err := uploadWithHooks(cmd, t, client, res); err != nil {
+		return err
+	}
+	return publishWithHooks(cmd, t, client, res)
+}
+
+// rollbackArtifact picks the archive to roll back to, the one that was asked
+// for by version or hash, or the one with the highest version below the
+// published version
+func rollbackArtifact(store artifacts.Store, extID, published, to string) (artifacts.Metadata, error) {
+	if to != "" {
+		meta, err := artifacts.Find(store, extID, to)
+		if err != nil {
+			return meta, fmt.Errorf("could not roll back to %v: %w", to, err)
+		}
+		return meta, nil
+	}
+	meta, err := artifacts.Latest(store, extID, published)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return meta, fmt.Errorf("no kept archive below the published version %v, pick one with --to", published)
+	}
+	return meta, err
+}
+
+// rewriteArtifact copies the kept archive with the version of the result, the
+// copy is removed when it could not be made
+func rewriteArtifact(res *result, store artifacts.Store, meta artifacts.Metadata) error {
+	src, err := os.CreateTemp("", "cws-artifact-*.zip")
+	if err != nil {
+		return err
+	}
+	src.Close()
+	defer os.Remove(src.Name())
+	dest, err := os.CreateTemp("", "cws-rollback-*.zip")
+	if err != nil {
+		return err
+	}
+	dest.Close()
+	res.ArchivePath = dest.Name()
+	err = spinner(res.Name, "Rewriting Archive", func() error {
+		if err := store.Fetch(meta, src.Name()); err != nil {
+			return err
+		} else if err := archive.Rewrite(src.Name(), res.ArchivePath, res.Version); err != nil {
+			return err
+		}
+		info, err := os.Stat(res.ArchivePath)
+		if err != nil {
+			return err
+		}
+		res.ArchiveSize = info.Size()
+		res.ArchiveHash, err = archive.SHA256(res.ArchivePath)
+		return err
+	})
+	if err != nil {
+		os.Remove(dest.Name())
+		res.ArchivePath = ""
+	}
+	return err
+}
+
+func confirmRollback(cmd *cobra.Command, res *result, published string) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	name := res.ExtensionID
+	if res.Name != "" {
+		name = res.Name
+	}
+	ok, err := term.Confirm(fmt.Sprintf("Replace %v %v with the archive of %v, published as %v?", name, published, res.RollbackFrom, res.Version))
+	if errors.Is(err, term.ErrNotInteractive) {
+		return errors.New("rollback needs to be confirmed, pass --yes when not running in a terminal")
+	} else if err != nil {
+		return err
+	} else if !ok {
+		return errors.New("rollback was cancelled")
+	}
+	return nil
+}
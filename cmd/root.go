@@ -5,10 +5,10 @@ import (
 	"os"
 	"time"
 
-	"github.com/akyoto/tty"
 	"github.com/spf13/cobra"
 
 	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/manifest"
 	"github.com/tanema/cws/lib/term"
 )
 
@@ -16,6 +16,9 @@ var rootCmd = &cobra.Command{
 	Use:     "cws",
 	Version: "0.0.1",
 	Short:   "A tool for managing chrome webstore extensions",
+	// errors are reported by Execute so that they map to an exit code
+	SilenceErrors: true,
+	SilenceUsage:  true,
 	Long: `A cli for automating or locally managing and publishing chrome webstore
 extensions. Best used in CI.
 
@@ -24,34 +27,81 @@ Env Vars:
   CWS_CLIENT_ID        google oauth client id
   CWS_CLIENT_SECRET    google oauth client secret
   CWS_REFRESH_TOKEN    google oauth client refresh token. Run cws init to get this value
+  CWS_PROFILE          named profile in the config to use
+  CWS_DEBUG            log every api request, the same as --log-level debug
+  CWS_HTTPS_PROXY      proxy for api requests, overrides HTTPS_PROXY
+  CWS_NO_PROXY         hosts that skip the proxy, overrides NO_PROXY
+  CWS_CA_FILES         comma separated PEM bundles to trust on top of the system roots
+  CWS_CLIENT_CERT      PEM client certificate for mTLS
+  CWS_CLIENT_KEY       PEM key of the client certificate
+  CWS_SECRETS_FILE     encrypted secrets file, defaults to $XDG_CONFIG_HOME/cws/secrets.age
+  CWS_SECRETS_PASSPHRASE passphrase that unlocks the encrypted secrets file
+  CWS_SECRETS_KEY      age key that unlocks the encrypted secrets file instead of a passphrase
+  CWS_LEDGER           release ledger file, defaults to $XDG_STATE_HOME/cws/ledger.jsonl
+  CWS_ARTIFACTS_DIR    store of uploaded archives, defaults to $XDG_CACHE_HOME/cws/artifacts
+  NO_COLOR             disable colored output
+  CLICOLOR_FORCE       force colored output even when not writing to a terminal
+
+Exit Codes:
+  0  success
+  1  unclassified error or bad usage
+  2  config error
+  3  auth error
+  4  validation error
+  5  upload failure
+  6  publish rejected
+  7  timeout
+  8  network error
+  9  hook failed or timed out
 `,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if noColor, _ := cmd.Flags().GetBool("no-color"); noColor {
+			term.SetColor(false)
+		}
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		term.SetQuiet(quiet)
+		if err := setupLogging(cmd); err != nil {
+			return err
+		}
+		return validateOutput(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
 	err := rootCmd.Execute()
+	saveRecordings()
 	if err != nil {
-		os.Exit(1)
+		printError(err)
+		os.Exit(exitCode(err))
 	}
 }
 
-func authenticate(cmd *cobra.Command) *gcloud.Client {
-	var client *gcloud.Client
-	var err error
-	err = term.Spinner("Authenticating", func() error {
-		client, err = gcloud.New(getString(cmd, "config"))
+func init() {
+	rootCmd.PersistentFlags().StringP("config", "c", "", "path to the config (default: chrome_webstore.json found by walking up to the repository root)")
+	rootCmd.PersistentFlags().String("profile", "", "named profile in the config to use, also set with CWS_PROFILE")
+	rootCmd.PersistentFlags().StringP("output", "o", outputText, "output format for results: text, json or yaml")
+	rootCmd.PersistentFlags().Bool("no-color", false, "disable colored output")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "only print results and errors, no progress output")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "build, validate and authenticate but only print the requests that would change the store listing")
+	addLogFlags(rootCmd)
+}
+
+func authenticate(config *gcloud.Config, name string) (client *gcloud.Client, err error) {
+	debugConfig(config)
+	err = spinner(name, "Authenticating", func() error {
+		client, err = gcloud.NewFromConfig(config)
 		return err
 	})
-	cobra.CheckErr(err)
-	return client
+	return client, withCode(exitAuth, err)
 }
 
-func getVersion(cmd *cobra.Command) string {
+func getVersion(cmd *cobra.Command) (string, error) {
 	if version := getString(cmd, "version"); version != "" {
-		return version
+		return version, manifest.ValidateVersion(version)
 	}
 	now := time.Now()
-	return fmt.Sprintf("%v.%v", now.Format("06.1.2"), ((now.Hour()*60 + now.Minute()) / 10))
+	return fmt.Sprintf("%v.%v", now.Format("06.1.2"), ((now.Hour()*60 + now.Minute()) / 10)), nil
 }
 
 func getString(cmd *cobra.Command, key string) string {
@@ -59,17 +109,3 @@ func getString(cmd *cobra.Command, key string) string {
 	cobra.CheckErr(err)
 	return value
 }
-
-func spinner(message string, fn func() error) error {
-	if tty.IsTerminal(os.Stdout.Fd()) {
-		return term.Spinner(message, fn)
-	}
-	fmt.Printf("🔄: %v", message)
-	err := fn()
-	if err != nil {
-		fmt.Printf("🔥: %v", message)
-	} else {
-		fmt.Printf("✅: %v", message)
-	}
-	return err
-}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/secrets"
+	"github.com/tanema/cws/lib/term"
+)
+
+var (
+	secretStoresOnce sync.Once
+	secretStoresOpen *secrets.Stores
+)
+
+// secretStores are shared by everything a command loads and saves, so the
+// encrypted secrets file is unlocked once. It is unlocked with
+// CWS_SECRETS_PASSPHRASE or by asking for the passphrase when running in a
+// terminal, and the passphrase is asked twice when the file is created.
+func secretStores() *secrets.Stores {
+	secretStoresOnce.Do(func() {
+		secretStoresOpen = secrets.NewStores(secrets.Options{
+			Passphrase:        askPassphrase("🔑 Passphrase for the secrets file: "),
+			ConfirmPassphrase: askPassphrase("🔑 Repeat the passphrase: "),
+		})
+	})
+	return secretStoresOpen
+}
+
+func askPassphrase(prompt string) func() (string, error) {
+	return func() (string, error) {
+		if pass := os.Getenv("CWS_SECRETS_PASSPHRASE"); pass != "" {
+			return pass, nil
+		}
+		return term.Password(prompt)
+	}
+}
+
+func addSecretStoreFlag(cmd *cobra.Command, value string) {
+	cmd.Flags().String("secret-store", value, "where to save secrets: keyring, age (an encrypted file) or plaintext (in the config)")
+}
+
+// saveSecret puts the value of key in the store picked with --secret-store
+// and returns what to write to the config in its place
+func saveSecret(cmd *cobra.Command, profile, key, value string) (string, error) {
+	kind := getString(cmd, "secret-store")
+	if kind == "" {
+		return value, nil
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	ref, err := secretStores().Save(kind, "cws/"+profile+"/"+key, value)
+	if err != nil && kind == secrets.KindKeyring {
+		return "", fmt.Errorf("%w, use --secret-store age when there is no keyring available", err)
+	}
+	return ref, err
+}
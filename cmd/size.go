@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/gcloud"
+)
+
+const sizesTmpl = `📦 {{with .Name}}[{{.}}] {{end}}{{.Archive | size | bold}} archive, {{.Files}} files of {{.Raw | size}} compressed to {{.Compressed | size}}
+{{- if .Previous}}, {{if gt .Growth 0}}{{printf "+%v" (.Growth | size) | yellow}}{{else}}{{.Growth | size | green}}{{end}} since the last artifact{{end}}
+{{printf "%-40v %6v %10v %10v" "Directory" "Files" "Raw" "Compressed" | bold}}
+{{- range .Dirs}}
+{{printf "%-40v %6v %10v %10v" .Path .Files (.Raw | size) (.Compressed | size)}}
+{{- end}}
+{{printf "%-40v %6v %10v %10v" "Largest files" "" "Raw" "Compressed" | bold}}
+{{- range .Largest}}
+{{printf "%-40v %6v %10v %10v" .Path "" (.Raw | size) (.Compressed | size)}}
+{{- end}}`
+
+const budgetTmpl = `{{range .}}❌ {{if eq .Budget "max_file_size"}}{{.Path | bold}} is {{.Size | size}}{{else if eq .Budget "max_growth"}}the archive grew by {{.Size | size}}{{else}}the archive is {{.Size | size}}{{end}}, over budgets.{{.Budget}} of {{.Limit | size | red}}
+{{end}}`
+
+// checkBudgets reads the sizes of the archive onto the result, reports them and
+// fails when they are over the budgets of the config. Growth is measured from
+// the kept artifact of the highest version below the new one.
+func checkBudgets(cmd *cobra.Command, config *gcloud.Config, res *result) error {
+	sizes, err := archive.ReadSizes(res.ArchivePath)
+	if err != nil {
+		return err
+	}
+	res.Sizes = sizes
+	budget, err := configBudget(config)
+	if err != nil {
+		return err
+	}
+	if config.ExtID != "" {
+		previous, err := artifacts.Latest(artifactStore(config), config.ExtID, res.Version)
+		if err == nil {
+			sizes.Previous = previous.Size
+		} else if !errors.Is(err, artifacts.ErrNotFound) {
+			return err
+		}
+	}
+	info(cmd, sizesTmpl, struct {
+		Name string
+		*archive.Sizes
+	}{res.Name, sizes})
+	if res.OverBudget = sizes.Check(budget); len(res.OverBudget) > 0 {
+		info(cmd, budgetTmpl, res.OverBudget)
+		return withCode(exitValidation, fmt.Errorf("the archive is over %v size budgets", len(res.OverBudget)))
+	}
+	return nil
+}
+
+// configBudget parses the budgets of the config
+func configBudget(config *gcloud.Config) (archive.Budget, error) {
+	budgets := config.Budgets
+	budget, err := archive.ParseBudget(budgets.MaxSize, budgets.MaxFileSize, budgets.MaxGrowth)
+	if err != nil {
+		return budget, withCode(exitConfig, fmt.Errorf("Configuration has an invalid budget: %w", err))
+	}
+	return budget, nil
+}
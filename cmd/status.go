@@ -1,29 +1,93 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
 	"github.com/tanema/cws/lib/gcloud"
 	"github.com/tanema/cws/lib/term"
 )
 
+const statusTmpl = `🕵️  {{"Status" | green}} {{with .Name}}{{. | bold}} {{end}}{{.ExtensionID | cyan}}{{if .DraftDiffers}} {{"draft differs from published" | yellow}}{{end}}
+{{printf "  %-16v %-24v %v" "" "Draft" "Published" | bold}}
+  {{printf "%-16v %-24v %v" "Version" (or .Draft.CRXVersion "-") (or .Published.CRXVersion "-")}}
+  {{printf "%-16v %-24v %v" "Upload State" (or .Draft.UploadState "-") (or .Published.UploadState "-")}}
+  {{printf "%-16v %-24v %v" "Publish Status" (or (.Draft.Status | join ", ") "-") (or (.Published.Status | join ", ") "-")}}
+  {{printf "%-16v %-24v %v" "Status Detail" (or (.Draft.Detail | join ", ") "-") (or (.Published.Detail | join ", ") "-")}}
+  {{printf "%-16v %-24v %v" "Rollout %" (or .Draft.DeployPercentage "-") (or .Published.DeployPercentage "-")}}
+{{- if or .Draft.ItemError .Published.ItemError}}
+{{"Item Errors" | red}}{{range .Draft.ItemError}}
+  {{"draft" | faint}}     {{.Code | yellow}} {{.Detail}}{{end}}{{range .Published.ItemError}}
+  {{"published" | faint}} {{.Code | yellow}} {{.Detail}}{{end}}
+{{- end}}`
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "check the publication status of your extension",
-	Run: func(cmd *cobra.Command, args []string) {
-		term.Println(`🕵️  {{"Status" | green}}{{with .Draft.CRXVersion}} Draft Version: {{. | bold}}{{end}}{{with .Published.CRXVersion}} Published Version: {{. | bold}}{{end}}`, status(authenticate(cmd)))
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, err := loadTargets(cmd, nil)
+		if err != nil {
+			return fail(cmd, &result{}, err)
+		}
+		reports := []statusReport{}
+		for _, t := range targets {
+			report, err := statusTarget(t)
+			if err != nil {
+				return fail(cmd, &result{Name: t.name, ExtensionID: t.config.ExtID}, err)
+			}
+			reports = append(reports, report)
+		}
+		format := getString(cmd, "format")
+		if format == "" && outputFormat(cmd) != outputText {
+			if len(reports) == 1 {
+				return render(cmd, "", reports[0])
+			}
+			return render(cmd, "", struct {
+				Results []statusReport `json:"results"`
+			}{reports})
+		} else if format == "" {
+			format = statusTmpl
+		}
+		for _, report := range reports {
+			if err := term.Fprintln(os.Stdout, format, report); err != nil {
+				return err
+			}
+		}
+		return nil
 	},
 }
 
+type statusReport struct {
+	Name        string `json:"name,omitempty"`
+	ExtensionID string `json:"extension_id"`
+	// DraftDiffers is derived from the versions, the api has no review state
+	DraftDiffers bool `json:"draft_differs"`
+	gcloud.WebStoreItemStatus
+}
+
 func init() {
 	rootCmd.AddCommand(statusCmd)
-	statusCmd.Flags().StringP("config", "c", "./chrome_webstore.json", "id of extension to deploy")
+	statusCmd.Flags().StringP("format", "f", "", "format the status report with a go template, e.g. '{{.Draft.CRXVersion}}'")
+	addTargetFlags(statusCmd)
+}
+
+func statusTarget(t target) (report statusReport, err error) {
+	report = statusReport{Name: t.name, ExtensionID: t.config.ExtID}
+	client, err := authenticate(t.config, t.name)
+	if err != nil {
+		return report, err
+	}
+	if report.WebStoreItemStatus, err = status(client, t.name); err != nil {
+		return report, err
+	}
+	report.DraftDiffers = report.WebStoreItemStatus.DraftDiffers()
+	return report, nil
 }
 
-func status(client *gcloud.Client) (status gcloud.WebStoreItemStatus) {
-	cobra.CheckErr(term.Spinner("Fetching Status", func() error {
-		var err error
+func status(client *gcloud.Client, name string) (status gcloud.WebStoreItemStatus, err error) {
+	err = spinner(name, "Fetching Status", func() error {
 		status, err = client.ExtensionStatus()
 		return err
-	}))
+	})
 	return
 }
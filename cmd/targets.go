@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tanema/cws/lib/gcloud"
+	"github.com/tanema/cws/lib/term"
+)
+
+const batchTmpl = `{{printf "%-20v %-16v %-14v %-20v %v" "Extension" "Version" "Upload State" "Publish Status" "Result" | bold}}
+{{- range .Results}}
+{{printf "%-20v %-16v %-14v %-20v" .Name (or .Version "-") (or .UploadState "-") (or (join ", " .PublishStatus) "-")}} {{if .Errors}}{{join "; " .Errors | red}}{{else if .DryRun}}{{"dry run" | yellow}}{{else}}{{"ok" | green}}{{end}}
+{{- range .DryRun}}
+  {{.Method | bold}} {{.URL | cyan}}{{with .PublishTarget}} target: {{. | bold}}{{end}}{{end}}
+{{- end}}`
+
+type (
+	// target is a single extension that a command runs against
+	target struct {
+		name          string
+		config        *gcloud.Config
+		source        string
+		manifestPatch string
+		public        bool
+	}
+	// batchResult is the output of a command that ran against several extensions
+	batchResult struct {
+		Results []*result `json:"results"`
+	}
+	targetFunc func(cmd *cobra.Command, t target, res *result) error
+)
+
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().String("only", "", "comma separated names of the configured extensions to run against")
+	cmd.Flags().Bool("all", false, "run against all of the configured extensions")
+	cmd.Flags().Int("concurrency", 4, "how many extensions to process at the same time")
+}
+
+// loadTargets finds the extensions a command should run against. A config
+// without named extensions is a single target, otherwise --only and --all pick
+// from the configured extensions.
+func loadTargets(cmd *cobra.Command, args []string) ([]target, error) {
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	test, _ := cmd.Flags().GetBool("test")
+	patch, _ := cmd.Flags().GetString("json")
+	source := ""
+	if len(args) > 0 {
+		source = args[0]
+	}
+	if len(config.Extensions) == 0 {
+		return []target{{config: config, source: source, manifestPatch: patch, public: !test}}, nil
+	}
+
+	extensions, err := selectExtensions(cmd, config)
+	if err != nil {
+		return nil, err
+	} else if source != "" && len(extensions) > 1 {
+		return nil, errors.New("a directory can only be given when running against a single extension")
+	}
+
+	targets := []target{}
+	for _, ext := range extensions {
+		t := target{
+			name:          ext.Name,
+			config:        config.ForExtension(ext),
+			source:        ext.Source,
+			manifestPatch: ext.ManifestPatch,
+			public:        !test && ext.PublishTarget != gcloud.PublishTarget(false),
+		}
+		if source != "" {
+			t.source = source
+		}
+		if patch != "" {
+			t.manifestPatch = patch
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// selectExtensions picks the configured extensions named by --only, or all of
+// them with --all or when there is only one
+func selectExtensions(cmd *cobra.Command, config *gcloud.Config) ([]gcloud.Extension, error) {
+	names := []string{}
+	if only, _ := cmd.Flags().GetString("only"); only != "" {
+		for _, name := range strings.Split(only, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	if all, _ := cmd.Flags().GetBool("all"); !all && len(names) == 0 && len(config.Extensions) > 1 {
+		return nil, withCode(exitConfig, errors.New("the config has several extensions, pick them with --only or use --all"))
+	}
+	extensions, err := config.Select(names)
+	if err != nil {
+		return nil, withCode(exitConfig, err)
+	}
+	return extensions, nil
+}
+
+// runTargets runs fn for every target. A single target is reported on its own,
+// several targets are run at most --concurrency at a time and reported in a
+// summary table. Commands with a --version flag get the version on the result,
+// it is resolved once so that every target of a batch gets the same one.
+func runTargets(cmd *cobra.Command, args []string, tmpl string, fn targetFunc) error {
+	targets, err := loadTargets(cmd, args)
+	if err != nil {
+		return fail(cmd, &result{}, err)
+	}
+	version := ""
+	if cmd.Flags().Lookup("version") != nil {
+		if version, err = getVersion(cmd); err != nil {
+			return fail(cmd, &result{}, err)
+		}
+	}
+	if isDryRun(cmd) {
+		tmpl = dryRunTmpl
+	}
+
+	if len(targets) == 1 {
+		res := &result{Name: targets[0].name, ExtensionID: targets[0].config.ExtID, Version: version}
+		if err := fn(cmd, targets[0], res); err != nil {
+			runFailureHook(cmd, targets[0], res, err)
+			return fail(cmd, res, err)
+		}
+		if err := render(cmd, tmpl, res); err != nil {
+			return err
+		}
+		if !isDryRun(cmd) {
+			info(cmd, "See package status at: {{. | blue}}", "https://chrome.google.com/webstore/devconsole")
+		}
+		return nil
+	}
+
+	// spinners running at the same time would redraw over each other
+	term.SetInteractive(false)
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	batch := batchResult{Results: make([]*result, len(targets))}
+	errs := make([]error, len(targets))
+	workers := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			res := &result{Name: t.name, ExtensionID: t.config.ExtID, Version: version}
+			if errs[i] = fn(cmd, t, res); errs[i] != nil {
+				runFailureHook(cmd, t, res, errs[i])
+				recordError(res, errs[i])
+			}
+			batch.Results[i] = res
+		}(i, t)
+	}
+	wg.Wait()
+
+	if err := render(cmd, batchTmpl, batch); err != nil {
+		return err
+	}
+	return batchError(errs)
+}
+
+// batchError summarizes the failures of a batch, exiting with the code of the
+// first failure
+func batchError(errs []error) error {
+	var first error
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return withCode(exitCode(first), fmt.Errorf("%v of %v extensions failed", failed, len(errs)))
+}
+
+// spinner prefixes the title with the extension name so that the output of
+// several extensions can be told apart
+func spinner(name, title string, fn func() error) error {
+	if name != "" {
+		title = fmt.Sprintf("[%v] %v", name, title)
+	}
+	return term.Spinner(title, fn)
+}
@@ -1,48 +1,78 @@
 package cmd
 
 import (
-	"os"
-
 	"github.com/spf13/cobra"
 	"github.com/tanema/cws/lib/gcloud"
-	"github.com/tanema/cws/lib/term"
+	"github.com/tanema/cws/lib/hooks"
+	"github.com/tanema/cws/lib/ledger"
+	"github.com/tanema/cws/lib/notify"
 )
 
 // uploadCmd represents the upload command
 var uploadCmd = &cobra.Command{
 	Use:   "upload [dir-path]",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	Short: "Upload a new package",
-	Run: func(cmd *cobra.Command, args []string) {
-		version := getVersion(cmd)
-		term.Println("🚚 Uploading Version: {{. | bold}}", version)
-		client := authenticate(cmd)
-		archivePath := archiveExt(args[0], version, getString(cmd, "json"))
-		defer os.Remove(archivePath)
-		item, err := upload(client, archivePath)
-		if err != nil {
-			term.Println(`{{. | bold}}`, err)
-			return
-		}
-		term.Println(`✅ {{.Version | bold}} {{"Upload Successful" | green}} Upload State: {{.State | bold}}`, struct {
-			State   string
-			Version string
-		}{item.UploadState, version})
-		term.Println("See package status at: {{. | blue}}", "https://chrome.google.com/webstore/devconsole")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTargets(cmd, args, `✅ {{.Version | bold}} {{"Upload Successful" | green}} Upload State: {{.UploadState | bold}}`, uploadTarget)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(uploadCmd)
-	uploadCmd.Flags().StringP("config", "c", "./chrome_webstore.json", "id of extension to deploy")
 	uploadCmd.Flags().StringP("version", "v", "", "version to add to the manifest (default: yy.mm.dd.nn)")
 	uploadCmd.Flags().StringP("json", "j", "", "json changes to the manifest. Should be formatted by key:value comma separated")
+	addPermissionFlags(uploadCmd)
+	addTargetFlags(uploadCmd)
+}
+
+func uploadTarget(cmd *cobra.Command, t target, res *result) (err error) {
+	info(cmd, "🚚 {{with .Name}}[{{.}}] {{end}}Uploading Version: {{.Version | bold}}", res)
+	defer removeArchive(res)
+	if err := buildArchive(cmd, t, res); err != nil {
+		return err
+	}
+	client, err := authenticate(t.config, res.Name)
+	if err != nil {
+		return err
+	}
+	if isDryRun(cmd) {
+		if err := dryRunStatus(client, res); err != nil {
+			return err
+		} else if err := checkPermissions(cmd, t, client, res); err != nil {
+			return err
+		}
+		planUpload(res, client.UploadRequest())
+		return nil
+	}
+	if err := checkPermissions(cmd, t, client, res); err != nil {
+		return err
+	}
+	return uploadWithHooks(cmd, t, client, res)
+}
+
+// uploadWithHooks runs the preupload and postupload hooks around the upload,
+// records and notifies about the result and caches the uploaded archive
+func uploadWithHooks(cmd *cobra.Command, t target, client *gcloud.Client, res *result) error {
+	if err := runHook(cmd, t, res, hooks.Preupload, nil); err != nil {
+		return err
+	}
+	err := upload(client, res)
+	recordRelease(t, res, ledger.ActionUpload, err)
+	notifyRelease(t, res, notify.EventUpload, err)
+	if err != nil {
+		return err
+	}
+	cacheArtifact(t, res)
+	return runHook(cmd, t, res, hooks.Postupload, nil)
 }
 
-func upload(client *gcloud.Client, archivePath string) (item gcloud.WebStoreItem, err error) {
-	term.Spinner("Uploading", func() error {
-		item, err = client.UploadExtension(archivePath)
+func upload(client *gcloud.Client, res *result) error {
+	var item gcloud.WebStoreItem
+	err := spinner(res.Name, "Uploading", func() (err error) {
+		item, err = client.UploadExtension(res.ArchivePath)
 		return err
 	})
-	return
+	res.ItemID, res.UploadState = item.ID, item.UploadState
+	return withCode(exitUpload, err)
 }
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile lists the files of the source directory that are left out of the
+// archive, one gitignore style pattern per line. It is never archived itself.
+const IgnoreFile = ".cwsignore"
+
+type (
+	// Ignore is the set of rules read from an IgnoreFile, later rules win
+	Ignore struct {
+		rules []ignoreRule
+	}
+	ignoreRule struct {
+		pattern string
+		negate  bool
+		dirOnly bool
+		// anchored patterns match from the root, others match a name at any depth
+		anchored bool
+	}
+)
+
+// LoadIgnore reads the IgnoreFile at the root of dir, a missing file ignores
+// nothing
+func LoadIgnore(dir string) (*Ignore, error) {
+	file, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Ignore{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseIgnore(file)
+}
+
+// ParseIgnore reads ignore rules, blank lines and lines starting with # are
+// skipped
+func ParseIgnore(src io.Reader) (*Ignore, error) {
+	ignore := &Ignore{}
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			ignore.rules = append(ignore.rules, rule)
+		}
+	}
+	return ignore, scanner.Err()
+}
+
+// Match reports whether the slash separated path, relative to the source
+// directory, is ignored. A file in an ignored directory is ignored.
+func (ignore *Ignore) Match(rel string, isDir bool) bool {
+	if rel == IgnoreFile {
+		return true
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if ignore.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return ignore.match(rel, isDir)
+}
+
+func (ignore *Ignore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range ignore.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if !rule.anchored {
+			name = path.Base(rel)
+		}
+		if matchGlob(rule.pattern, name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlob matches a path against a pattern where ** matches any number of
+// directories and the other wildcards match within a directory
+func matchGlob(pattern, name string) bool {
+	patterns, names := strings.Split(pattern, "/"), strings.Split(name, "/")
+	var match func(p, n int) bool
+	match = func(p, n int) bool {
+		for ; p < len(patterns); p++ {
+			if patterns[p] == "**" {
+				for skip := n; skip <= len(names); skip++ {
+					if match(p+1, skip) {
+						return true
+					}
+				}
+				return false
+			}
+			if n >= len(names) {
+				return false
+			} else if ok, _ := path.Match(patterns[p], names[n]); !ok {
+				return false
+			}
+			n++
+		}
+		return n == len(names)
+	}
+	return match(0, 0)
+}
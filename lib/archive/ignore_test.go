@@ -0,0 +1,69 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreMatch(t *testing.T) {
+	ignore, err := ParseIgnore(strings.NewReader(`
+# comments and blank lines are skipped
+
+*.map
+/build.log
+src/
+docs/**/*.md
+!keep.map
+`))
+	require.Nil(t, err)
+	for rel, ignored := range map[string]bool{
+		".cwsignore":         true,
+		"main.js":            false,
+		"main.js.map":        true,
+		"js/vendor/lib.map":  true,
+		"keep.map":           false,
+		"build.log":          true,
+		"js/build.log":       false,
+		"src/index.ts":       true,
+		"lib/src/index.ts":   true,
+		"docs/README.md":     true,
+		"docs/a/b/GUIDE.md":  true,
+		"docs/logo.png":      false,
+		"srcfile":            false,
+		"notdocs/README.md":  false,
+		"src/deep/nested.js": true,
+	} {
+		assert.Equal(t, ignored, ignore.Match(rel, false), rel)
+	}
+	assert.True(t, ignore.Match("src", true))
+	assert.False(t, ignore.Match("src", false), "directory patterns only match directories")
+}
+
+func TestZipIgnore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "src"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, IgnoreFile), []byte("src/\n*.map\n"), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name": "ext", "version": "0.0.1"}`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "main.js"), []byte(`main()`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "main.js.map"), []byte(`{}`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "src", "main.ts"), []byte(`main()`), 0600))
+
+	dest, err := ZipTo(filepath.Join(t.TempDir(), "ext.zip"), dir, "1.0", "")
+	require.Nil(t, err)
+	files := readZip(t, dest)
+	assert.Contains(t, files, "dist/manifest.json")
+	assert.Contains(t, files, "dist/main.js")
+	assert.Len(t, files, 2)
+
+	pkg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"main.js", "manifest.json"}, pkg.Paths())
+	assert.Equal(t, []string{"main.js.map", "src/"}, pkg.Ignored)
+	assert.True(t, pkg.IsIgnored("src/main.ts"))
+	assert.False(t, pkg.IsIgnored("main.js"))
+}
@@ -0,0 +1,176 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is the files of an extension read from a directory, a zip or a crx.
+// Paths are relative to the directory of the manifest so that a directory and
+// the archive made from it have the same paths.
+type Package struct {
+	// Source is where the package was read from
+	Source string
+	Files  map[string][]byte
+	// Ignored are the files of a directory that the IgnoreFile leaves out of
+	// the archive, ignored directories end with a slash and are not listed
+	Ignored []string
+}
+
+// crxMagic starts every crx file, the zip follows a version specific header
+var crxMagic = []byte("Cr24")
+
+// Load reads the package at path, which can be a directory, a zip or a crx
+func Load(src string) (*Package, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &Package{Source: src, Files: map[string][]byte{}}
+	if info.IsDir() {
+		err = pkg.readDir(src)
+	} else {
+		err = pkg.readFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pkg.trimRoot()
+	return pkg, nil
+}
+
+// Paths lists the files of the package in order
+func (pkg *Package) Paths() []string {
+	paths := make([]string, 0, len(pkg.Files))
+	for name := range pkg.Files {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Manifest is the contents of manifest.json
+func (pkg *Package) Manifest() ([]byte, error) {
+	data, ok := pkg.Files["manifest.json"]
+	if !ok {
+		return nil, fmt.Errorf("no manifest.json in %v", pkg.Source)
+	}
+	return data, nil
+}
+
+// Size is the uncompressed size of every file
+func (pkg *Package) Size() int64 {
+	var size int64
+	for _, data := range pkg.Files {
+		size += int64(len(data))
+	}
+	return size
+}
+
+func (pkg *Package) readDir(dir string) error {
+	ignore, err := LoadIgnore(dir)
+	if err != nil {
+		return err
+	}
+	return walk(dir, ignore, func(file string, info os.FileInfo) error {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		pkg.Files[filepath.ToSlash(rel)], err = os.ReadFile(file)
+		return err
+	}, func(rel string, isDir bool) {
+		if isDir {
+			rel += "/"
+		}
+		pkg.Ignored = append(pkg.Ignored, rel)
+	})
+}
+
+// IsIgnored reports whether a path of a directory package was left out by
+// the IgnoreFile, and so is missing from Files
+func (pkg *Package) IsIgnored(name string) bool {
+	for _, ignored := range pkg.Ignored {
+		if name == ignored || (strings.HasSuffix(ignored, "/") && strings.HasPrefix(name, ignored)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pkg *Package) readFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, crxMagic) {
+		if data, err = crxZip(data); err != nil {
+			return fmt.Errorf("reading %v: %w", file, err)
+		}
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", file, err)
+	}
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		pkg.Files[entry.Name], err = io.ReadAll(content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crxZip strips the header of a crx2 or crx3 file, leaving the zip
+func crxZip(data []byte) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("truncated crx header")
+	}
+	version := binary.LittleEndian.Uint32(data[4:8])
+	var offset uint64
+	switch version {
+	case 2:
+		if len(data) < 16 {
+			return nil, errors.New("truncated crx header")
+		}
+		offset = 16 + uint64(binary.LittleEndian.Uint32(data[8:12])) + uint64(binary.LittleEndian.Uint32(data[12:16]))
+	case 3:
+		offset = 12 + uint64(binary.LittleEndian.Uint32(data[8:12]))
+	default:
+		return nil, fmt.Errorf("unsupported crx version %v", version)
+	}
+	if offset > uint64(len(data)) {
+		return nil, errors.New("truncated crx header")
+	}
+	return data[offset:], nil
+}
+
+// trimRoot makes paths relative to the manifest closest to the root, archives
+// made by Zip keep the name of the source directory in their paths
+func (pkg *Package) trimRoot() {
+	root := manifestRoot(pkg.Paths())
+	if root == "" {
+		return
+	}
+	files := map[string][]byte{}
+	for name, data := range pkg.Files {
+		files[strings.TrimPrefix(name, root)] = data
+	}
+	pkg.Files = files
+}
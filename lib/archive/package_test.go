@@ -0,0 +1,52 @@
+package archive
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "js"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name": "ext", "version": "1.0"}`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "js", "main.js"), []byte(`console.log("hi")`), 0600))
+
+	fromDir, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"js/main.js", "manifest.json"}, fromDir.Paths())
+	assert.Equal(t, int64(50), fromDir.Size())
+
+	zipPath, err := ZipTo(filepath.Join(t.TempDir(), "ext.zip"), dir, "1.0", "")
+	require.Nil(t, err)
+	fromZip, err := Load(zipPath)
+	require.Nil(t, err)
+	assert.Equal(t, fromDir.Paths(), fromZip.Paths(), "the directory name is trimmed from the archive paths")
+	manifest, err := fromZip.Manifest()
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"name": "ext", "version": "1.0"}`, string(manifest))
+
+	zipData, err := os.ReadFile(zipPath)
+	require.Nil(t, err)
+	header := make([]byte, 12)
+	copy(header, "Cr24")
+	binary.LittleEndian.PutUint32(header[4:], 3)
+	binary.LittleEndian.PutUint32(header[8:], 4)
+	crxPath := filepath.Join(t.TempDir(), "ext.crx")
+	require.Nil(t, os.WriteFile(crxPath, append(append(header, "sig!"...), zipData...), 0600))
+	fromCrx, err := Load(crxPath)
+	require.Nil(t, err)
+	assert.Equal(t, fromZip.Files, fromCrx.Files)
+
+	binary.LittleEndian.PutUint32(header[8:], 1<<20)
+	require.Nil(t, os.WriteFile(crxPath, append(header, zipData...), 0600))
+	_, err = Load(crxPath)
+	assert.ErrorContains(t, err, "truncated crx header")
+
+	_, err = Load(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
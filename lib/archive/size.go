@@ -0,0 +1,245 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LargestFiles is how many files Sizes lists in Largest
+const LargestFiles = 10
+
+// Budgets that an archive can be over
+const (
+	BudgetMaxSize     = "max_size"
+	BudgetMaxFileSize = "max_file_size"
+	BudgetMaxGrowth   = "max_growth"
+)
+
+type (
+	// Sizes is how the size of an archive is made up, paths are relative to
+	// the manifest like in a Package
+	Sizes struct {
+		// Archive is the size of the zip file
+		Archive int64 `json:"archive"`
+		// Raw and Compressed are the total of the files before and after
+		// compression
+		Raw        int64 `json:"raw"`
+		Compressed int64 `json:"compressed"`
+		Files      int   `json:"files"`
+		// Previous is the size of the archive that growth is measured from, 0
+		// when there is none
+		Previous int64 `json:"previous,omitempty"`
+		// Dirs are the top level directories by raw size, files in the root of
+		// the package are under "."
+		Dirs []SizeEntry `json:"dirs"`
+		// Largest are the largest files by raw size
+		Largest []SizeEntry `json:"largest"`
+
+		all []SizeEntry
+	}
+	// SizeEntry is the size of a file or of the files in a directory
+	SizeEntry struct {
+		Path       string `json:"path"`
+		Files      int    `json:"files,omitempty"`
+		Raw        int64  `json:"raw"`
+		Compressed int64  `json:"compressed"`
+	}
+	// Budget limits the size of an archive, size limits that are 0 are not
+	// checked
+	Budget struct {
+		// MaxSize is the largest the zip file can be
+		MaxSize int64
+		// MaxFileSize is the largest a file can be before compression
+		MaxFileSize int64
+		// MaxGrowth and MaxGrowthPercent are how much the zip file can grow
+		// since the previous archive, growth is only checked when LimitGrowth
+		// is set so that 0 allows no growth at all
+		MaxGrowth        int64
+		MaxGrowthPercent float64
+		LimitGrowth      bool
+	}
+	// Violation is a budget that an archive is over
+	Violation struct {
+		Budget string `json:"budget"`
+		// Path is the file that is too large for max_file_size
+		Path  string `json:"path,omitempty"`
+		Size  int64  `json:"size"`
+		Limit int64  `json:"limit"`
+	}
+)
+
+// ReadSizes reports the sizes of an archive made by Zip, so that it can be
+// checked against a Budget
+func ReadSizes(archivePath string) (*Sizes, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	sizes := &Sizes{Archive: info.Size(), Dirs: []SizeEntry{}, Largest: []SizeEntry{}, all: []SizeEntry{}}
+	names := []string{}
+	for _, entry := range reader.File {
+		if !entry.FileInfo().IsDir() {
+			names = append(names, entry.Name)
+		}
+	}
+	root := manifestRoot(names)
+	dirs := map[string]*SizeEntry{}
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		file := SizeEntry{
+			Path:       strings.TrimPrefix(entry.Name, root),
+			Raw:        int64(entry.UncompressedSize64),
+			Compressed: int64(entry.CompressedSize64),
+		}
+		sizes.all = append(sizes.all, file)
+		sizes.Files++
+		sizes.Raw += file.Raw
+		sizes.Compressed += file.Compressed
+
+		dir, _, found := strings.Cut(file.Path, "/")
+		if !found {
+			dir = "."
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = &SizeEntry{Path: dir}
+		}
+		dirs[dir].Files++
+		dirs[dir].Raw += file.Raw
+		dirs[dir].Compressed += file.Compressed
+	}
+	for _, dir := range dirs {
+		sizes.Dirs = append(sizes.Dirs, *dir)
+	}
+	sortBySize(sizes.Dirs)
+	sortBySize(sizes.all)
+	sizes.Largest = append(sizes.Largest, sizes.all[:min(len(sizes.all), LargestFiles)]...)
+	return sizes, nil
+}
+
+// Growth is how much larger the archive is than the previous one
+func (sizes *Sizes) Growth() int64 {
+	if sizes.Previous == 0 {
+		return 0
+	}
+	return sizes.Archive - sizes.Previous
+}
+
+// Check lists the budgets the archive is over, growth is only checked when
+// there is a Previous archive
+func (sizes *Sizes) Check(budget Budget) []Violation {
+	violations := []Violation{}
+	if budget.MaxSize > 0 && sizes.Archive > budget.MaxSize {
+		violations = append(violations, Violation{Budget: BudgetMaxSize, Size: sizes.Archive, Limit: budget.MaxSize})
+	}
+	if budget.MaxFileSize > 0 {
+		for _, file := range sizes.all {
+			if file.Raw > budget.MaxFileSize {
+				violations = append(violations, Violation{Budget: BudgetMaxFileSize, Path: file.Path, Size: file.Raw, Limit: budget.MaxFileSize})
+			}
+		}
+	}
+	if limit := budget.growthLimit(sizes.Previous); sizes.Previous > 0 && limit >= 0 && sizes.Growth() > limit {
+		violations = append(violations, Violation{Budget: BudgetMaxGrowth, Size: sizes.Growth(), Limit: limit})
+	}
+	return violations
+}
+
+// growthLimit is the growth allowed from previous, -1 when it is not limited
+func (budget Budget) growthLimit(previous int64) int64 {
+	if !budget.LimitGrowth {
+		return -1
+	} else if budget.MaxGrowthPercent > 0 {
+		return int64(float64(previous) * budget.MaxGrowthPercent / 100)
+	}
+	return budget.MaxGrowth
+}
+
+// ParseBudget reads the limits of a Budget from sizes like 10MB. maxGrowth is a
+// size or a percentage like 10%, and growth is only limited when it is set so
+// that "0" or "0%" allows no growth. Limits that are empty are not checked.
+func ParseBudget(maxSize, maxFileSize, maxGrowth string) (Budget, error) {
+	budget := Budget{}
+	for _, limit := range []struct {
+		key   string
+		value string
+		size  *int64
+	}{{BudgetMaxSize, maxSize, &budget.MaxSize}, {BudgetMaxFileSize, maxFileSize, &budget.MaxFileSize}} {
+		if limit.value == "" {
+			continue
+		}
+		size, err := ParseSize(limit.value)
+		if err != nil {
+			return budget, fmt.Errorf("invalid %v %q, expected a size like 10MB", limit.key, limit.value)
+		}
+		*limit.size = size
+	}
+	if maxGrowth == "" {
+		return budget, nil
+	}
+	var err error
+	budget.LimitGrowth = true
+	if percent, ok := strings.CutSuffix(maxGrowth, "%"); ok {
+		budget.MaxGrowthPercent, err = strconv.ParseFloat(strings.TrimSpace(percent), 64)
+	} else {
+		budget.MaxGrowth, err = ParseSize(maxGrowth)
+	}
+	if err != nil || budget.MaxGrowthPercent < 0 {
+		return budget, fmt.Errorf("invalid %v %q, expected a size like 500KB or a percentage like 10%%", BudgetMaxGrowth, maxGrowth)
+	}
+	return budget, nil
+}
+
+// ParseSize reads a size like 10MB, 1.5 GB or 512, units are powers of 1024
+func ParseSize(value string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, multiplier = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix)), unit.size
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a size like 10MB", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// manifestRoot is the directory of the manifest closest to the root, with a
+// trailing slash, or "" when the manifest is in the root
+func manifestRoot(names []string) string {
+	found := ""
+	for _, name := range names {
+		if path.Base(name) == "manifest.json" && (found == "" || depth(name) < depth(found) ||
+			(depth(name) == depth(found) && name < found)) {
+			found = name
+		}
+	}
+	return strings.TrimSuffix(found, "manifest.json")
+}
+
+func sortBySize(entries []SizeEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Raw != entries[j].Raw {
+			return entries[i].Raw > entries[j].Raw
+		}
+		return entries[i].Path < entries[j].Path
+	})
+}
@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSizes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "js", "vendor"), 0700))
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "img"), 0700))
+	for name, size := range map[string]int{"js/main.js": 3000, "js/vendor/lib.js": 5000, "img/logo.svg": 1000, "popup.html": 200} {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(strings.Repeat("a", size)), 0600))
+	}
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name": "ext", "version": "0.0.1"}`), 0600))
+
+	zipPath, err := ZipTo(filepath.Join(t.TempDir(), "ext.zip"), dir, "1.0", "")
+	require.Nil(t, err)
+	sizes, err := ReadSizes(zipPath)
+	require.Nil(t, err)
+
+	info, err := os.Stat(zipPath)
+	require.Nil(t, err)
+	assert.Equal(t, info.Size(), sizes.Archive)
+	assert.Equal(t, 5, sizes.Files)
+	manifestSize := sizes.Raw - 9200
+	assert.Equal(t, []string{"js", "img", "."}, entryPaths(sizes.Dirs))
+	assert.Equal(t, SizeEntry{Path: "js", Files: 2, Raw: 8000, Compressed: sizes.Dirs[0].Compressed}, sizes.Dirs[0])
+	assert.Equal(t, 200+manifestSize, sizes.Dirs[2].Raw)
+	assert.Less(t, sizes.Compressed, sizes.Raw)
+	assert.Equal(t, []string{"js/vendor/lib.js", "js/main.js", "img/logo.svg", "popup.html", "manifest.json"}, entryPaths(sizes.Largest))
+
+	assert.Equal(t, []Violation{}, sizes.Check(Budget{}))
+	assert.Equal(t, []Violation{
+		{Budget: BudgetMaxSize, Size: sizes.Archive, Limit: 100},
+		{Budget: BudgetMaxFileSize, Path: "js/vendor/lib.js", Size: 5000, Limit: 2048},
+		{Budget: BudgetMaxFileSize, Path: "js/main.js", Size: 3000, Limit: 2048},
+	}, sizes.Check(Budget{MaxSize: 100, MaxFileSize: 2048, MaxGrowth: 1, LimitGrowth: true}))
+
+	sizes.Previous = sizes.Archive - 100
+	assert.Equal(t, int64(100), sizes.Growth())
+	assert.Equal(t, []Violation{}, sizes.Check(Budget{}))
+	assert.Equal(t, []Violation{}, sizes.Check(Budget{MaxGrowth: 100, LimitGrowth: true}))
+	assert.Equal(t, []Violation{{Budget: BudgetMaxGrowth, Size: 100, Limit: 99}}, sizes.Check(Budget{MaxGrowth: 99, LimitGrowth: true}))
+	assert.Equal(t, []Violation{{Budget: BudgetMaxGrowth, Size: 100, Limit: sizes.Previous / 100}}, sizes.Check(Budget{MaxGrowthPercent: 1, LimitGrowth: true}))
+	assert.Equal(t, []Violation{{Budget: BudgetMaxGrowth, Size: 100, Limit: 0}}, sizes.Check(Budget{LimitGrowth: true}))
+}
+
+func entryPaths(entries []SizeEntry) []string {
+	paths := []string{}
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+func TestParseSize(t *testing.T) {
+	for value, want := range map[string]int64{"512": 512, "10MB": 10 << 20, "1.5 gb": 3 << 29, "20k": 20 << 10, "100B": 100} {
+		size, err := ParseSize(value)
+		require.Nil(t, err, value)
+		assert.Equal(t, want, size, value)
+	}
+	for _, value := range []string{"", "ten MB", "-1MB", "10%"} {
+		_, err := ParseSize(value)
+		assert.ErrorContains(t, err, "invalid size", value)
+	}
+}
+
+func TestParseBudget(t *testing.T) {
+	budget, err := ParseBudget("", "", "")
+	require.Nil(t, err)
+	assert.Equal(t, Budget{}, budget)
+
+	budget, err = ParseBudget("10MB", "512KB", "10%")
+	require.Nil(t, err)
+	assert.Equal(t, Budget{MaxSize: 10 << 20, MaxFileSize: 512 << 10, MaxGrowthPercent: 10, LimitGrowth: true}, budget)
+	budget, err = ParseBudget("", "", "500KB")
+	require.Nil(t, err)
+	assert.Equal(t, Budget{MaxGrowth: 500 << 10, LimitGrowth: true}, budget)
+	budget, err = ParseBudget("", "", "0%")
+	require.Nil(t, err)
+	assert.Equal(t, Budget{LimitGrowth: true}, budget)
+
+	_, err = ParseBudget("big", "", "")
+	assert.EqualError(t, err, `invalid max_size "big", expected a size like 10MB`)
+	_, err = ParseBudget("", "", "-5%")
+	assert.ErrorContains(t, err, "invalid max_growth")
+}
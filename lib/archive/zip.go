@@ -3,9 +3,14 @@ package archive
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/tanema/cws/lib/manifest"
 )
@@ -13,7 +18,17 @@ import (
 // Zip will create a new zip archive file and update the manifest for publishing
 // with the new version added and the dev key removed
 func Zip(dir, version, jsonChangeset string) (string, error) {
-	file, err := os.Create("compiled_extension.zip")
+	return ZipTo("compiled_extension.zip", dir, version, jsonChangeset)
+}
+
+// ZipTo is the same as Zip but writes the archive to dest. Files matched by
+// the IgnoreFile of dir are left out.
+func ZipTo(dest, dir, version, jsonChangeset string) (string, error) {
+	ignore, err := LoadIgnore(dir)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(dest)
 	if err != nil {
 		return "", err
 	}
@@ -22,10 +37,7 @@ func Zip(dir, version, jsonChangeset string) (string, error) {
 	writer := zip.NewWriter(file)
 	defer writer.Close()
 
-	return file.Name(), filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return err
-		}
+	return file.Name(), walk(dir, ignore, func(path string, info os.FileInfo) error {
 		header, err := zip.FileInfoHeader(info)
 		if err != nil {
 			return err
@@ -47,6 +59,32 @@ func Zip(dir, version, jsonChangeset string) (string, error) {
 		defer data.Close()
 		_, err = io.Copy(headerWriter, data)
 		return err
+	}, nil)
+}
+
+// walk calls fn for each file in dir that is not ignored, ignored directories
+// are skipped without reading them. skip, when set, is called with the slash
+// separated path of each ignored file and directory.
+func walk(dir string, ignore *Ignore, fn func(path string, info os.FileInfo) error, skip func(rel string, isDir bool)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		} else if rel = filepath.ToSlash(rel); ignore.Match(rel, info.IsDir()) {
+			if skip != nil && rel != IgnoreFile {
+				skip(rel, info.IsDir())
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if info.IsDir() {
+			return nil
+		}
+		return fn(path, info)
 	})
 }
 
@@ -57,3 +95,96 @@ func getFile(path, version, jsonChangeset string) (io.ReadCloser, error) {
 	manifestBytes, err := manifest.UpdateBytes(path, version, jsonChangeset)
 	return io.NopCloser(bytes.NewBuffer(manifestBytes)), err
 }
+
+// Rewrite copies the archive at src to dest with the version of its manifest
+// set to version, for publishing an archive that was already built again
+func Rewrite(src, dest, version string) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for _, entry := range reader.File {
+		if err := rewriteEntry(writer, entry, version); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func rewriteEntry(writer *zip.Writer, entry *zip.File, version string) error {
+	data, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	header := entry.FileHeader
+	dest, err := writer.CreateHeader(&header)
+	if err != nil {
+		return err
+	} else if path.Base(entry.Name) != "manifest.json" {
+		_, err = io.Copy(dest, data)
+		return err
+	}
+	manifestBytes, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	if manifestBytes, err = manifest.UpdateData(manifestBytes, version, ""); err != nil {
+		return err
+	}
+	_, err = dest.Write(manifestBytes)
+	return err
+}
+
+// ReadManifest returns the manifest.json of the archive, the one closest to
+// the root when there are several
+func ReadManifest(archivePath string) ([]byte, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var found *zip.File
+	for _, entry := range reader.File {
+		if path.Base(entry.Name) == "manifest.json" && (found == nil || depth(entry.Name) < depth(found.Name)) {
+			found = entry
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no manifest.json in %v", archivePath)
+	}
+	data, err := found.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	return io.ReadAll(data)
+}
+
+func depth(name string) int {
+	return strings.Count(name, "/")
+}
+
+// SHA256 will return the hex encoded sha256 checksum of the file at path
+func SHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "js"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name": "ext", "version": "0.0.1", "key": "dev"}`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "js", "main.js"), []byte(`console.log("hi")`), 0600))
+
+	src, err := ZipTo(filepath.Join(t.TempDir(), "src.zip"), dir, "1.0", "")
+	require.Nil(t, err)
+	dest := filepath.Join(t.TempDir(), "dest.zip")
+	require.Nil(t, Rewrite(src, dest, "1.2"))
+
+	files := readZip(t, dest)
+	assert.JSONEq(t, `{"name": "ext", "version": "1.2"}`, files["dist/manifest.json"])
+	assert.Equal(t, `console.log("hi")`, files["dist/js/main.js"])
+	assert.Len(t, files, 2)
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	reader, err := zip.OpenReader(path)
+	require.Nil(t, err)
+	defer reader.Close()
+	files := map[string]string{}
+	for _, file := range reader.File {
+		data, err := file.Open()
+		require.Nil(t, err)
+		content, err := io.ReadAll(data)
+		require.Nil(t, err)
+		data.Close()
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "vendor", "lib"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "vendor", "lib", "manifest.json"), []byte(`{"name": "vendored"}`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name": "ext", "version": "0.0.1"}`), 0600))
+
+	src, err := ZipTo(filepath.Join(t.TempDir(), "src.zip"), dir, "1.0", "")
+	require.Nil(t, err)
+	data, err := ReadManifest(src)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"name": "ext", "version": "1.0"}`, string(data))
+
+	empty := filepath.Join(t.TempDir(), "empty.zip")
+	file, err := os.Create(empty)
+	require.Nil(t, err)
+	require.Nil(t, zip.NewWriter(file).Close())
+	file.Close()
+	_, err = ReadManifest(empty)
+	assert.ErrorContains(t, err, "no manifest.json")
+}
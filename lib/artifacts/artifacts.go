@@ -0,0 +1,134 @@
+// Package artifacts keeps the archives that were uploaded so that the exact
+// package of a version can be inspected or published again. Each archive is
+// stored with a metadata sidecar that records what it was built from.
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tanema/cws/lib/manifest"
+)
+
+// ErrNotFound is returned when there is no artifact for a version or hash
+var ErrNotFound = errors.New("artifact not found")
+
+type (
+	// Store keeps artifacts. Local is the default, other stores, like a bucket
+	// shared by CI, only need to implement this interface.
+	Store interface {
+		// Put saves the archive at archivePath with its metadata, replacing an
+		// artifact with the same version and hash
+		Put(meta Metadata, archivePath string) error
+		// List returns the artifacts of an extension, or of every extension
+		// when extID is empty, oldest first
+		List(extID string) ([]Metadata, error)
+		// Fetch writes the archive of an artifact to dest
+		Fetch(meta Metadata, dest string) error
+		// Delete removes an artifact and its metadata
+		Delete(meta Metadata) error
+	}
+	// Metadata is the sidecar saved next to each archive
+	Metadata struct {
+		ExtensionID string `json:"extension_id"`
+		// Name is the name of the extension in a config with several extensions
+		Name     string          `json:"name,omitempty"`
+		Version  string          `json:"version"`
+		SHA256   string          `json:"sha256"`
+		Size     int64           `json:"size"`
+		Commit   string          `json:"commit,omitempty"`
+		Created  time.Time       `json:"created"`
+		Manifest json.RawMessage `json:"manifest,omitempty"`
+	}
+	// Retention decides which artifacts are pruned. The newest artifact of each
+	// extension is always kept.
+	Retention struct {
+		// Keep is how many artifacts of each extension are kept, 0 keeps all
+		Keep int
+		// MaxAge removes artifacts older than it, 0 keeps all
+		MaxAge time.Duration
+	}
+)
+
+// Find returns the newest artifact of an extension with a version, or a
+// sha256 prefix of at least 7 characters, matching ref
+func Find(store Store, extID, ref string) (Metadata, error) {
+	list, err := store.List(extID)
+	if err != nil {
+		return Metadata{}, err
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Version == ref || (len(ref) >= 7 && strings.HasPrefix(list[i].SHA256, ref)) {
+			return list[i], nil
+		}
+	}
+	return Metadata{}, fmt.Errorf("%v %v: %w", extID, ref, ErrNotFound)
+}
+
+// Latest returns the artifact of an extension with the highest version below
+// the given version, the newest one when a version was built more than once
+func Latest(store Store, extID, below string) (Metadata, error) {
+	list, err := store.List(extID)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var latest *Metadata
+	for i := range list {
+		if manifest.CompareVersions(list[i].Version, below) < 0 &&
+			(latest == nil || manifest.CompareVersions(list[i].Version, latest.Version) >= 0) {
+			latest = &list[i]
+		}
+	}
+	if latest == nil {
+		return Metadata{}, fmt.Errorf("%v below %v: %w", extID, below, ErrNotFound)
+	}
+	return *latest, nil
+}
+
+// Prune deletes the artifacts that the retention policy does not keep and
+// returns them. With dryRun nothing is deleted.
+func Prune(store Store, policy Retention, now time.Time, dryRun bool) ([]Metadata, error) {
+	list, err := store.List("")
+	if err != nil {
+		return nil, err
+	}
+	byExt := map[string][]Metadata{}
+	for _, meta := range list {
+		byExt[meta.ExtensionID] = append(byExt[meta.ExtensionID], meta)
+	}
+	pruned := []Metadata{}
+	for _, metas := range byExt {
+		// newest first so that the index is the rank of the artifact
+		for i, j := 0, len(metas)-1; i < j; i, j = i+1, j-1 {
+			metas[i], metas[j] = metas[j], metas[i]
+		}
+		for i, meta := range metas {
+			tooMany := policy.Keep > 0 && i >= policy.Keep
+			tooOld := policy.MaxAge > 0 && now.Sub(meta.Created) > policy.MaxAge
+			if i == 0 || (!tooMany && !tooOld) {
+				continue
+			} else if !dryRun {
+				if err := store.Delete(meta); err != nil {
+					return pruned, err
+				}
+			}
+			pruned = append(pruned, meta)
+		}
+	}
+	sortMetadata(pruned)
+	return pruned, nil
+}
+
+// sortMetadata orders artifacts oldest first
+func sortMetadata(list []Metadata) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].Created.Equal(list[j].Created) {
+			return list[i].Created.Before(list[j].Created)
+		}
+		return manifest.CompareVersions(list[i].Version, list[j].Version) < 0
+	})
+}
@@ -0,0 +1,114 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func putArtifact(t *testing.T, store Store, extID, version, hash string, created time.Time) Metadata {
+	archive := filepath.Join(t.TempDir(), "extension.zip")
+	require.Nil(t, os.WriteFile(archive, []byte(version+hash), 0600))
+	meta := Metadata{ExtensionID: extID, Version: version, SHA256: strings.Repeat(hash, 64), Size: 4, Commit: "deadbeef", Created: created}
+	require.Nil(t, store.Put(meta, archive))
+	return meta
+}
+
+func TestLocal(t *testing.T) {
+	store := Local{Dir: filepath.Join(t.TempDir(), "artifacts")}
+	list, err := store.List("abc")
+	require.Nil(t, err)
+	assert.Empty(t, list)
+
+	old := putArtifact(t, store, "abc", "1.10", "a", start)
+	rebuilt := putArtifact(t, store, "abc", "1.10", "b", start.Add(time.Hour))
+	other := putArtifact(t, store, "def", "2.0", "c", start.Add(2*time.Hour))
+
+	list, err = store.List("abc")
+	require.Nil(t, err)
+	assert.Equal(t, []Metadata{old, rebuilt}, list)
+	list, err = store.List("")
+	require.Nil(t, err)
+	assert.Equal(t, []Metadata{old, rebuilt, other}, list)
+	assert.FileExists(t, filepath.Join(store.Dir, "abc", "1.10-aaaaaaaaaaaa.json"))
+	assert.Equal(t, filepath.Join(store.Dir, "abc", "1.10-bbbbbbbbbbbb.zip"), store.Path(rebuilt))
+
+	dest := filepath.Join(t.TempDir(), "out.zip")
+	require.Nil(t, store.Fetch(old, dest))
+	data, err := os.ReadFile(dest)
+	require.Nil(t, err)
+	assert.Equal(t, "1.10a", string(data))
+
+	require.Nil(t, store.Delete(old))
+	list, err = store.List("abc")
+	require.Nil(t, err)
+	assert.Equal(t, []Metadata{rebuilt}, list)
+	assert.ErrorIs(t, store.Fetch(old, dest), ErrNotFound)
+}
+
+func TestLocalInvalid(t *testing.T) {
+	store := Local{Dir: t.TempDir()}
+	assert.ErrorContains(t, store.Put(Metadata{ExtensionID: "../abc", Version: "1.0", SHA256: "ab"}, "x.zip"), "invalid extension id")
+	assert.ErrorContains(t, store.Put(Metadata{ExtensionID: "abc", Version: "../1.0", SHA256: "ab"}, "x.zip"), "invalid version")
+	assert.ErrorContains(t, store.Put(Metadata{ExtensionID: "abc", Version: "1.0", SHA256: "../ab"}, "x.zip"), "invalid artifact hash")
+	_, err := store.List("..")
+	assert.ErrorContains(t, err, "invalid extension id")
+}
+
+func TestFindAndLatest(t *testing.T) {
+	store := Local{Dir: t.TempDir()}
+	putArtifact(t, store, "abc", "1.9", "a", start)
+	v110 := putArtifact(t, store, "abc", "1.10", "b", start.Add(time.Hour))
+	rebuilt := putArtifact(t, store, "abc", "1.10", "c", start.Add(2*time.Hour))
+	v2 := putArtifact(t, store, "abc", "2.0", "d", start.Add(3*time.Hour))
+
+	meta, err := Find(store, "abc", "1.10")
+	require.Nil(t, err)
+	assert.Equal(t, rebuilt, meta)
+	meta, err = Find(store, "abc", "bbbbbbb")
+	require.Nil(t, err)
+	assert.Equal(t, v110, meta)
+	_, err = Find(store, "abc", "bbb")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	meta, err = Latest(store, "abc", "2.1")
+	require.Nil(t, err)
+	assert.Equal(t, v2, meta)
+	meta, err = Latest(store, "abc", "2.0")
+	require.Nil(t, err)
+	assert.Equal(t, rebuilt, meta)
+	_, err = Latest(store, "abc", "1.9")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPrune(t *testing.T) {
+	store := Local{Dir: t.TempDir()}
+	a1 := putArtifact(t, store, "abc", "1.0", "a", start)
+	a2 := putArtifact(t, store, "abc", "1.1", "b", start.Add(24*time.Hour))
+	a3 := putArtifact(t, store, "abc", "1.2", "c", start.Add(48*time.Hour))
+	d1 := putArtifact(t, store, "def", "1.0", "d", start)
+	now := start.Add(72 * time.Hour)
+
+	pruned, err := Prune(store, Retention{}, now, false)
+	require.Nil(t, err)
+	assert.Empty(t, pruned)
+
+	pruned, err = Prune(store, Retention{Keep: 2}, now, true)
+	require.Nil(t, err)
+	assert.Equal(t, []Metadata{a1}, pruned)
+	list, _ := store.List("")
+	assert.Len(t, list, 4, "a dry run does not delete")
+
+	pruned, err = Prune(store, Retention{MaxAge: 36 * time.Hour}, now, false)
+	require.Nil(t, err)
+	assert.Equal(t, []Metadata{a1, a2}, pruned, "the newest artifact of each extension is kept")
+	list, _ = store.List("")
+	assert.Equal(t, []Metadata{d1, a3}, list)
+}
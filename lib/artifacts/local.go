@@ -0,0 +1,166 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tanema/cws/lib/manifest"
+)
+
+// Local stores artifacts in a directory, as <extension id>/<version>-<hash>.zip
+// with the metadata in a .json file next to it
+type Local struct {
+	Dir string
+}
+
+// DefaultDir is the local store in $XDG_CACHE_HOME/cws/artifacts
+func DefaultDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "cws", "artifacts")
+}
+
+// Put copies the archive into the directory and writes its metadata
+func (local Local) Put(meta Metadata, archivePath string) error {
+	if err := validate(meta); err != nil {
+		return err
+	}
+	base := local.base(meta)
+	if err := os.MkdirAll(filepath.Dir(base), 0700); err != nil {
+		return err
+	}
+	if meta.Created.IsZero() {
+		meta.Created = time.Now().UTC()
+	}
+	src, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := writeFile(base+".zip", src); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(base+".json", strings.NewReader(string(data)))
+}
+
+// List reads the metadata in the directory, archives without metadata are
+// ignored
+func (local Local) List(extID string) ([]Metadata, error) {
+	pattern := filepath.Join(local.Dir, "*", "*.json")
+	if extID != "" {
+		if err := validateID(extID); err != nil {
+			return nil, err
+		}
+		pattern = filepath.Join(local.Dir, extID, "*.json")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	list := []Metadata{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var meta Metadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("reading artifact %v: %w", path, err)
+		}
+		list = append(list, meta)
+	}
+	sortMetadata(list)
+	return list, nil
+}
+
+// Fetch copies the archive of the artifact to dest
+func (local Local) Fetch(meta Metadata, dest string) error {
+	if err := validate(meta); err != nil {
+		return err
+	}
+	src, err := os.Open(local.base(meta) + ".zip")
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%v %v: %w", meta.ExtensionID, meta.Version, ErrNotFound)
+	} else if err != nil {
+		return err
+	}
+	defer src.Close()
+	return writeFile(dest, src)
+}
+
+// Delete removes the archive and its metadata
+func (local Local) Delete(meta Metadata) error {
+	if err := validate(meta); err != nil {
+		return err
+	}
+	base := local.base(meta)
+	if err := os.Remove(base + ".json"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(base + ".zip"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Path is where the archive of an artifact is stored
+func (local Local) Path(meta Metadata) string {
+	return local.base(meta) + ".zip"
+}
+
+func (local Local) base(meta Metadata) string {
+	hash := meta.SHA256
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return filepath.Join(local.Dir, meta.ExtensionID, meta.Version+"-"+hash)
+}
+
+// writeFile copies src to a temp file first so that a failed copy does not
+// leave a broken file behind
+func writeFile(dest string, src io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	} else if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// validate keeps the metadata from pointing outside of the directory
+func validate(meta Metadata) error {
+	if err := validateID(meta.ExtensionID); err != nil {
+		return err
+	} else if err := manifest.ValidateVersion(meta.Version); err != nil {
+		return err
+	} else if meta.SHA256 == "" || strings.Trim(meta.SHA256, "0123456789abcdef") != "" {
+		return fmt.Errorf("invalid artifact hash %q", meta.SHA256)
+	}
+	return nil
+}
+
+func validateID(extID string) error {
+	if extID == "" || extID == "." || extID == ".." || strings.ContainsAny(extID, `/\`) {
+		return fmt.Errorf("invalid extension id %q", extID)
+	}
+	return nil
+}
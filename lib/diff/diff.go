@@ -0,0 +1,227 @@
+// Package diff compares two packages of an extension, the files that were
+// added, removed or changed, the manifest and the permissions it asks for.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/manifest"
+)
+
+// Kinds of change
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+type (
+	// Options changes what Compare reports
+	Options struct {
+		// Unified adds a unified diff of each text file that changed
+		Unified bool
+		// Context is the number of unchanged lines around each change
+		Context int
+	}
+	// Report is the difference between package A and package B
+	Report struct {
+		A           string                      `json:"a"`
+		B           string                      `json:"b"`
+		SizeA       int64                       `json:"size_a"`
+		SizeB       int64                       `json:"size_b"`
+		Added       int                         `json:"added"`
+		Removed     int                         `json:"removed"`
+		Changed     int                         `json:"changed"`
+		Files       []File                      `json:"files"`
+		Manifest    []Key                       `json:"manifest"`
+		Permissions []manifest.PermissionChange `json:"permissions"`
+	}
+	// File is a file that is not the same in both packages
+	File struct {
+		Path   string `json:"path"`
+		Change string `json:"change"`
+		SizeA  int64  `json:"size_a"`
+		SizeB  int64  `json:"size_b"`
+		Binary bool   `json:"binary,omitempty"`
+		Diff   string `json:"diff,omitempty"`
+	}
+	// Key is a manifest value that is not the same in both manifests, nested
+	// keys are joined with dots and arrays are compared as a whole
+	Key struct {
+		Key    string      `json:"key"`
+		Change string      `json:"change"`
+		A      interface{} `json:"a,omitempty"`
+		B      interface{} `json:"b,omitempty"`
+	}
+)
+
+// Delta is the growth of the package
+func (report Report) Delta() int64 {
+	return report.SizeB - report.SizeA
+}
+
+// Delta is the growth of the file
+func (file File) Delta() int64 {
+	return file.SizeB - file.SizeA
+}
+
+// Compare reports what changed from package a to package b
+func Compare(a, b *archive.Package, opts Options) (*Report, error) {
+	report := &Report{
+		A:           a.Source,
+		B:           b.Source,
+		SizeA:       a.Size(),
+		SizeB:       b.Size(),
+		Files:       []File{},
+		Manifest:    []Key{},
+		Permissions: []manifest.PermissionChange{},
+	}
+	for _, name := range paths(a, b) {
+		dataA, inA := a.Files[name]
+		dataB, inB := b.Files[name]
+		file := File{Path: name, SizeA: int64(len(dataA)), SizeB: int64(len(dataB))}
+		switch {
+		case !inA:
+			file.Change = Added
+			report.Added++
+		case !inB:
+			file.Change = Removed
+			report.Removed++
+		case !bytes.Equal(dataA, dataB):
+			file.Change = Changed
+			report.Changed++
+		default:
+			continue
+		}
+		file.Binary = isBinary(dataA) || isBinary(dataB)
+		if opts.Unified && !file.Binary {
+			nameA, nameB := "a/"+name, "b/"+name
+			if !inA {
+				nameA = "/dev/null"
+			} else if !inB {
+				nameB = "/dev/null"
+			}
+			file.Diff = Unified(nameA, nameB, string(dataA), string(dataB), opts.Context)
+		}
+		report.Files = append(report.Files, file)
+	}
+	manifestA, errA := a.Manifest()
+	manifestB, errB := b.Manifest()
+	if errA != nil || errB != nil {
+		// a package without a manifest only has its files compared
+		return report, nil
+	}
+	var err error
+	if report.Manifest, err = compareManifests(manifestA, manifestB); err != nil {
+		return nil, err
+	}
+	permsA, err := manifest.ParsePermissions(manifestA)
+	if err != nil {
+		return nil, err
+	}
+	permsB, err := manifest.ParsePermissions(manifestB)
+	if err != nil {
+		return nil, err
+	}
+	report.Permissions = manifest.DiffPermissions(permsA, permsB)
+	return report, nil
+}
+
+func paths(a, b *archive.Package) []string {
+	set := map[string]bool{}
+	for name := range a.Files {
+		set[name] = true
+	}
+	for name := range b.Files {
+		set[name] = true
+	}
+	list := make([]string, 0, len(set))
+	for name := range set {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// isBinary guesses like git does, from a nul byte near the start, and also
+// treats anything that is not utf-8 as binary
+func isBinary(data []byte) bool {
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(data)
+}
+
+func compareManifests(a, b []byte) ([]Key, error) {
+	valsA, valsB := map[string]interface{}{}, map[string]interface{}{}
+	for _, side := range []struct {
+		data []byte
+		vals map[string]interface{}
+	}{{a, valsA}, {b, valsB}} {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(side.data, &parsed); err != nil {
+			return nil, &manifest.ValidationError{Msg: "error unmarshalling manifest", Err: err}
+		}
+		flatten("", parsed, side.vals)
+	}
+	keys := map[string]bool{}
+	for key := range valsA {
+		keys[key] = true
+	}
+	for key := range valsB {
+		keys[key] = true
+	}
+	changes := []Key{}
+	for key := range keys {
+		valA, inA := valsA[key]
+		valB, inB := valsB[key]
+		change := Key{Key: key, A: valA, B: valB}
+		switch {
+		case !inA:
+			change.Change = Added
+		case !inB:
+			change.Change = Removed
+		case !jsonEqual(valA, valB):
+			change.Change = Changed
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+func flatten(prefix string, obj map[string]interface{}, vals map[string]interface{}) {
+	for key, val := range obj {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := val.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(key, nested, vals)
+		} else {
+			vals[key] = val
+		}
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	dataA, _ := json.Marshal(a)
+	dataB, _ := json.Marshal(b)
+	return bytes.Equal(dataA, dataB)
+}
+
+// splitLines keeps the line endings out of the lines, a missing newline at
+// the end of the text is not treated as a change
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
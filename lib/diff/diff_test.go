@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/manifest"
+)
+
+func TestCompare(t *testing.T) {
+	a := &archive.Package{Source: "a", Files: map[string][]byte{
+		"manifest.json": []byte(`{"name": "ext", "version": "1.0", "permissions": ["storage"], "action": {"default_popup": "popup.html"}}`),
+		"popup.html":    []byte("<html>\n<body>\n</body>\n</html>\n"),
+		"old.js":        []byte("old()\n"),
+		"icon.png":      {0x89, 'P', 'N', 'G', 0},
+	}}
+	b := &archive.Package{Source: "b", Files: map[string][]byte{
+		"manifest.json": []byte(`{"name": "ext", "version": "1.1", "permissions": ["storage", "tabs", "https://*.example.com/*"], "action": {"default_popup": "popup.html"}}`),
+		"popup.html":    []byte("<html>\n<body>\nhi\n</body>\n</html>\n"),
+		"new.js":        []byte("new()\n"),
+		"icon.png":      {0x89, 'P', 'N', 'G', 0},
+	}}
+
+	report, err := Compare(a, b, Options{Unified: true, Context: 1})
+	require.Nil(t, err)
+	assert.Equal(t, 1, report.Added)
+	assert.Equal(t, 1, report.Removed)
+	assert.Equal(t, 2, report.Changed)
+	assert.Equal(t, report.SizeB-report.SizeA, report.Delta())
+	require.Len(t, report.Files, 4)
+	assert.Equal(t, File{Path: "new.js", Change: Added, SizeB: 6, Diff: "--- /dev/null\n+++ b/new.js\n@@ -0,0 +1 @@\n+new()\n"}, report.Files[1])
+	assert.Equal(t, Removed, report.Files[2].Change)
+	assert.Equal(t, int64(-6), report.Files[2].Delta())
+	assert.Equal(t, "--- a/popup.html\n+++ b/popup.html\n@@ -2,2 +2,3 @@\n <body>\n+hi\n </body>\n", report.Files[3].Diff)
+
+	assert.Equal(t, []Key{
+		{Key: "permissions", Change: Changed, A: []interface{}{"storage"}, B: []interface{}{"storage", "tabs", "https://*.example.com/*"}},
+		{Key: "version", Change: Changed, A: "1.0", B: "1.1"},
+	}, report.Manifest)
+	assert.Equal(t, []manifest.PermissionChange{
+		{Field: manifest.FieldPermissions, Permission: "tabs", Added: true, Warning: "Read your browsing history"},
+		{Field: manifest.FieldHostPermissions, Permission: "https://*.example.com/*", Added: true, Warning: "Read and change your data on all example.com sites"},
+	}, report.Permissions)
+}
+
+func TestCompareBinary(t *testing.T) {
+	a := &archive.Package{Files: map[string][]byte{"icon.png": {0x89, 'P', 'N', 'G', 0, 1}}}
+	b := &archive.Package{Files: map[string][]byte{"icon.png": {0x89, 'P', 'N', 'G', 0, 2}}}
+	report, err := Compare(a, b, Options{Unified: true})
+	require.Nil(t, err)
+	assert.Equal(t, []File{{Path: "icon.png", Change: Changed, SizeA: 6, SizeB: 6, Binary: true}}, report.Files)
+	assert.Empty(t, report.Manifest, "packages without a manifest only compare files")
+}
+
+func TestUnified(t *testing.T) {
+	assert.Equal(t, "", Unified("a", "b", "same\n", "same", 3))
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2x\n3\n4\n5\n6\n7\n8\n9x\n10\n"
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+2x\n 3\n@@ -8,3 +8,3 @@\n 8\n-9\n+9x\n 10\n", Unified("a", "b", a, b, 1))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,10 +1,10 @@\n 1\n-2\n+2x\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+9x\n 10\n", Unified("a", "b", a, b, 3))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1 +0,0 @@\n-gone\n", Unified("a", "b", "gone\n", "", 3))
+}
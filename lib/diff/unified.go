@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxEdits caps the work of comparing two very different files, like two
+// builds of a minified bundle, where a line diff would not be readable anyway
+const maxEdits = 2000
+
+// edit is a line of a diff, op is ' ' for a line in both texts, '-' for a
+// line only in a and '+' for a line only in b
+type edit struct {
+	op   byte
+	line string
+}
+
+// Unified is the unified diff of two texts with context lines around each
+// change, it is empty when the texts have the same lines
+func Unified(nameA, nameB, a, b string, context int) string {
+	edits, ok := lineDiff(splitLines(a), splitLines(b))
+	if !ok {
+		return fmt.Sprintf("--- %v\n+++ %v\n@@ too many changes to show a diff @@\n", nameA, nameB)
+	}
+	var out strings.Builder
+	for _, hunk := range hunks(edits, context) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %v\n+++ %v\n", nameA, nameB)
+		}
+		out.WriteString(hunk)
+	}
+	return out.String()
+}
+
+// lineDiff finds the shortest edit script from a to b with the Myers
+// algorithm, it gives up when more than maxEdits lines changed
+func lineDiff(a, b []string) ([]edit, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	// trace keeps the furthest x of each diagonal before each round, for
+	// walking back from the end
+	trace := [][]int{}
+	for d := 0; d <= max; d++ {
+		if d > maxEdits {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), true
+			}
+		}
+	}
+	return backtrack(a, b, trace), true
+}
+
+func backtrack(a, b []string, trace [][]int) []edit {
+	edits := []edit{}
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			edits = append(edits, edit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// hunks groups the changes with their context, changes that are closer than
+// twice the context share a hunk
+func hunks(edits []edit, context int) []string {
+	if context < 0 {
+		context = 0
+	}
+	out := []string{}
+	for start := 0; start < len(edits); {
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		last := first
+		for i := first; i < len(edits) && i-last <= 2*context+1; i++ {
+			if edits[i].op != ' ' {
+				last = i
+			}
+		}
+		from, to := first-context, last+context+1
+		if from < start {
+			from = start
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		out = append(out, hunk(edits, from, to))
+		start = to
+	}
+	return out
+}
+
+func hunk(edits []edit, from, to int) string {
+	lineA, lineB := 1, 1
+	for _, e := range edits[:from] {
+		if e.op != '+' {
+			lineA++
+		}
+		if e.op != '-' {
+			lineB++
+		}
+	}
+	var body strings.Builder
+	countA, countB := 0, 0
+	for _, e := range edits[from:to] {
+		if e.op != '+' {
+			countA++
+		}
+		if e.op != '-' {
+			countB++
+		}
+		body.WriteByte(e.op)
+		body.WriteString(e.line)
+		body.WriteByte('\n')
+	}
+	return fmt.Sprintf("@@ -%v +%v @@\n%v", hunkRange(lineA, countA), hunkRange(lineB, countB), body.String())
+}
+
+// hunkRange is written like diff -u does, an empty range starts at the line
+// before it
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%v,%v", line, count)
+}
@@ -2,9 +2,6 @@ package gcloud
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
@@ -24,9 +21,10 @@ type (
 		TokenType    string `json:"token_type"`
 		Scope        string `json:"scope"`
 	}
+	// Authenticator runs the oauth flow for the client id and secret of a
+	// config, sending the token exchange through the config's http client
 	Authenticator struct {
-		id     string
-		secret string
+		config *Config
 		scopes string
 		state  string
 		server http.Server
@@ -36,15 +34,14 @@ type (
 
 const serverhost = "localhost:3333"
 
-func NewAuthenticator(clientID, clientSecret, scopes string) *Authenticator {
+func NewAuthenticator(config *Config, scopes string) *Authenticator {
 	return &Authenticator{
-		id:     clientID,
-		secret: clientSecret,
+		config: config,
 		scopes: scopes,
 		server: http.Server{Addr: serverhost},
 		conf: &oauth2.Config{
-			ClientID:     clientID,
-			ClientSecret: clientSecret,
+			ClientID:     config.ID,
+			ClientSecret: config.Secret,
 			RedirectURL:  "http://" + serverhost,
 			Scopes:       strings.Split(scopes, " "),
 			Endpoint:     google.Endpoint,
@@ -66,8 +63,8 @@ func (auth *Authenticator) ListForResponse() (*Config, error) {
 		return nil, err
 	}
 	return &Config{
-		ID:           auth.id,
-		Secret:       auth.secret,
+		ID:           auth.config.ID,
+		Secret:       auth.config.Secret,
 		RefreshToken: access.RefreshToken,
 	}, nil
 }
@@ -98,25 +95,22 @@ func (auth *Authenticator) startAuthServer(codeChan chan string) {
 func (auth *Authenticator) exchangeCode(code string) (*AuthAccess, error) {
 	form := url.Values{}
 	form.Set("code", code)
-	form.Set("client_id", auth.id)
-	form.Set("client_secret", auth.secret)
+	form.Set("client_id", auth.config.ID)
+	form.Set("client_secret", auth.config.Secret)
 	form.Set("redirect_uri", "http://"+serverhost)
 	form.Set("grant_type", "authorization_code")
-	resp, err := http.Post(
-		"https://oauth2.googleapis.com/token",
-		"application/x-www-form-urlencoded",
-		strings.NewReader(form.Encode()),
-	)
+	req, err := http.NewRequest(http.MethodPost, "https://oauth2.googleapis.com/token", strings.NewReader(form.Encode()))
 	if err != nil {
-		return nil, fmt.Errorf("request: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient, err := auth.config.HTTPClient()
 	if err != nil {
-		return nil, fmt.Errorf("reading resp body: %v", err)
+		return nil, err
 	}
+	client := &Client{Config: auth.config, http: httpClient}
 	codeResp := &AuthAccess{}
-	return codeResp, json.Unmarshal(bodyBytes, codeResp)
+	return codeResp, client.do(req, codeResp)
 }
 
 func genState() string {
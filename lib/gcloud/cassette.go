@@ -0,0 +1,186 @@
+package gcloud
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type (
+	// Cassette is an http.RoundTripper that records exchanges with the api to a
+	// file, or replays them from it so that the client can be tested offline.
+	// Requests are matched on method, path, query and a hash of the body, host
+	// is ignored. Tokens and secrets are redacted before anything is recorded.
+	Cassette struct {
+		Path string
+		Mode CassetteMode
+		// Next sends requests while recording, defaults to http.DefaultTransport
+		Next http.RoundTripper
+		// Redact hides secrets in recorded bodies, tokens are always redacted
+		Redact func(string) string
+
+		mu           sync.Mutex
+		interactions []Interaction
+		used         []bool
+	}
+	// CassetteMode is whether a cassette records or replays
+	CassetteMode int
+	// Interaction is a recorded request and response
+	Interaction struct {
+		Method     string      `json:"method"`
+		Path       string      `json:"path"`
+		Query      string      `json:"query,omitempty"`
+		BodySHA256 string      `json:"body_sha256,omitempty"`
+		Status     int         `json:"status"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body"`
+	}
+)
+
+// Cassette modes
+const (
+	Replay CassetteMode = iota
+	Record
+)
+
+// ErrNoInteraction is returned when replaying a request that was not recorded
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// NewCassette opens the cassette at path. Replaying needs the file to exist,
+// recording starts over.
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	cassette := &Cassette{Path: path, Mode: mode}
+	if mode == Record {
+		return cassette, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cassette.interactions); err != nil {
+		return nil, fmt.Errorf("parsing cassette %v: %w", path, err)
+	}
+	cassette.used = make([]bool, len(cassette.interactions))
+	return cassette, nil
+}
+
+// RoundTrip records or replays a single exchange
+func (cassette *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	key := Interaction{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Query:      req.URL.Query().Encode(),
+		BodySHA256: cassette.hash(body),
+	}
+	if cassette.Mode == Record {
+		return cassette.record(req, key)
+	}
+	return cassette.replay(req, key)
+}
+
+// Save writes the recorded interactions to the cassette file
+func (cassette *Cassette) Save() error {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	data, err := json.MarshalIndent(cassette.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cassette.Path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(cassette.Path, data, 0600)
+}
+
+func (cassette *Cassette) record(req *http.Request, interaction Interaction) (*http.Response, error) {
+	next := cassette.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	interaction.Status = resp.StatusCode
+	interaction.Body = cassette.redact(string(body))
+	interaction.Header = http.Header{}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		interaction.Header.Set("Content-Type", contentType)
+	}
+	cassette.mu.Lock()
+	cassette.interactions = append(cassette.interactions, interaction)
+	cassette.used = append(cassette.used, true)
+	cassette.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// replay answers with the first unused interaction that matches, so that the
+// same request can be replayed with different responses in order
+func (cassette *Cassette) replay(req *http.Request, key Interaction) (*http.Response, error) {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	for i, interaction := range cassette.interactions {
+		if cassette.used[i] || interaction.Method != key.Method || interaction.Path != key.Path ||
+			interaction.Query != key.Query || interaction.BodySHA256 != key.BodySHA256 {
+			continue
+		}
+		cassette.used[i] = true
+		header := interaction.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Body))),
+			ContentLength: int64(len(interaction.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %v %v?%v", ErrNoInteraction, key.Method, key.Path, key.Query)
+}
+
+// hash is taken after redaction so that a cassette recorded with real
+// credentials still matches requests made with test credentials
+func (cassette *Cassette) hash(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cassette.redact(string(body))))
+	return hex.EncodeToString(sum[:])
+}
+
+func (cassette *Cassette) redact(text string) string {
+	text = redactTokens(text)
+	if cassette.Redact != nil {
+		text = cassette.Redact(text)
+	}
+	return text
+}
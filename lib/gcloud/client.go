@@ -2,22 +2,25 @@ package gcloud
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
-
-	"github.com/tanema/cws/lib/term"
+	"time"
 )
 
 type (
 	// Client acts as a client to gcloud apis
 	Client struct {
 		token  string
+		http   *http.Client
 		Config *Config
 	}
 	WebStoreItemError struct {
@@ -25,8 +28,8 @@ type (
 		Detail string `json:"error_detail"`
 	}
 	WebStoreItemStatus struct {
-		Draft     WebStoreItem
-		Published WebStoreItem
+		Draft     WebStoreItem `json:"draft"`
+		Published WebStoreItem `json:"published"`
 	}
 	WebStoreItem struct {
 		ID          string              `json:"id"`
@@ -37,6 +40,8 @@ type (
 		ItemError   []WebStoreItemError `json:"itemError"`
 		Status      []string            `json:"status"`
 		Detail      []string            `json:"statusDetail"`
+		// DeployPercentage is only reported for items with a staged rollout
+		DeployPercentage int `json:"deployPercentage,omitempty"`
 	}
 	gcloudTokenResp struct {
 		AccessToken string `json:"access_token"`
@@ -58,15 +63,45 @@ type (
 	webStoreErrorResp struct {
 		Error webStoreError `json:"error"`
 	}
+	// Request describes a call to the api that changes the store listing, it is
+	// used to report what would be sent in a dry run
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	}
+	oauthErrorResp struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+)
+
+// Failed requests are retried up to maxRetries times, waiting retryBackoff
+// and doubling it for every retry
+var (
+	maxRetries   = 2
+	retryBackoff = 500 * time.Millisecond
 )
 
+// requestTimeout is generous so that large archives can still be uploaded on
+// slow connections, it only exists so that a hung request does not hang forever
+const requestTimeout = 10 * time.Minute
+
 // New creates a new gcloud client
-func New(configPath string) (*Client, error) {
-	config, err := loadConfig(configPath)
+func New(opts LoadOptions) (*Client, error) {
+	config, err := LoadConfig(opts)
 	if err != nil {
 		return nil, err
 	}
-	client := &Client{Config: config}
+	return NewFromConfig(config)
+}
+
+// NewFromConfig creates a new gcloud client from an already loaded config
+func NewFromConfig(config *Config) (*Client, error) {
+	httpClient, err := config.HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{Config: config, http: httpClient}
 	return client, client.authenticate()
 }
 
@@ -76,10 +111,19 @@ func (client *Client) authenticate() error {
 	params.Set("client_secret", client.Config.Secret)
 	params.Set("refresh_token", client.Config.RefreshToken)
 	params.Set("grant_type", "refresh_token")
+	req, err := http.NewRequest(http.MethodPost, "https://oauth2.googleapis.com/token", strings.NewReader(params.Encode()))
+	if err != nil {
+		return &AuthError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	resp := gcloudTokenResp{}
-	err := client.doRequest(http.MethodPost, "https://oauth2.googleapis.com/token?"+params.Encode(), nil, &resp)
+	if err := client.do(req, &resp); err != nil {
+		return &AuthError{Err: err}
+	} else if resp.AccessToken == "" {
+		return &AuthError{Err: errors.New("no access token was returned")}
+	}
 	client.token = resp.AccessToken
-	return err
+	return nil
 }
 
 func (client *Client) ExtensionStatus() (WebStoreItemStatus, error) {
@@ -95,6 +139,13 @@ func (client *Client) ExtensionStatus() (WebStoreItemStatus, error) {
 	return status, nil
 }
 
+// DraftDiffers is true when the draft has another version than the published
+// item. The api does not report the review state, so this only says that a
+// newer version was uploaded, it may be in review or not submitted yet.
+func (status WebStoreItemStatus) DraftDiffers() bool {
+	return status.Draft.CRXVersion != "" && status.Draft.CRXVersion != status.Published.CRXVersion
+}
+
 func (client *Client) CreateExtension(archivePath string) (WebStoreItem, error) {
 	resp := WebStoreItem{}
 	archive, err := os.Open(archivePath)
@@ -102,11 +153,12 @@ func (client *Client) CreateExtension(archivePath string) (WebStoreItem, error)
 		return resp, err
 	}
 	defer archive.Close()
-	if err := client.doRequest(http.MethodPost, "https://www.googleapis.com/upload/chromewebstore/v1.1/items?uploadType=media", archive, &resp); err != nil {
+	req := client.CreateRequest()
+	if err := client.doRequest(req.Method, req.URL, archive, &resp); err != nil {
 		return resp, err
 	}
 	if resp.UploadState != "SUCCESS" {
-		return resp, resp
+		return resp, &UploadError{State: resp.UploadState, Errors: resp.ItemError}
 	}
 	return resp, nil
 }
@@ -118,78 +170,212 @@ func (client *Client) UploadExtension(archivePath string) (WebStoreItem, error)
 		return resp, err
 	}
 	defer archive.Close()
-	url := "https://www.googleapis.com/upload/chromewebstore/v1.1/items/" + client.Config.ExtID + "?uploadType=media"
-	if err := client.doRequest(http.MethodPut, url, archive, &resp); err != nil {
+	req := client.UploadRequest()
+	if err := client.doRequest(req.Method, req.URL, archive, &resp); err != nil {
 		return resp, err
 	}
 	if resp.UploadState != "SUCCESS" {
-		return resp, resp
+		return resp, &UploadError{State: resp.UploadState, Errors: resp.ItemError}
 	}
 	return resp, nil
 }
 
 func (client *Client) PublishExtension(public bool) (WebStoreItem, error) {
 	resp := WebStoreItem{}
-	target := "trustedTesters"
-	if public {
-		target = "default"
-	}
-	url := "https://www.googleapis.com/chromewebstore/v1.1/items/" + client.Config.ExtID + "/publish?publishTarget=" + target
-	if err := client.doRequest(http.MethodPost, url, nil, &resp); err != nil {
+	req := client.PublishRequest(public)
+	if err := client.doRequest(req.Method, req.URL, nil, &resp); err != nil {
 		return resp, err
 	}
 	if !reflect.DeepEqual(resp.Status, []string{"OK"}) {
-		return resp, fmt.Errorf("Failed to publish extension with status: %v errors: %v", strings.Join(resp.Status, ", "), strings.Join(resp.Detail, ", "))
+		return resp, &PublishError{Status: resp.Status, Detail: resp.Detail}
 	}
 	return resp, nil
 }
 
-func (client *Client) doRequest(method, url string, body io.Reader, respData interface{}) error {
-	if client.Config.Debug {
-		fmt.Println("REQUESTION:", method, url)
+// CreateRequest describes the request CreateExtension sends
+func (client *Client) CreateRequest() Request {
+	return Request{
+		Method: http.MethodPost,
+		URL:    "https://www.googleapis.com/upload/chromewebstore/v1.1/items?uploadType=media",
+	}
+}
+
+// UploadRequest describes the request UploadExtension sends
+func (client *Client) UploadRequest() Request {
+	return Request{
+		Method: http.MethodPut,
+		URL:    "https://www.googleapis.com/upload/chromewebstore/v1.1/items/" + client.Config.ExtID + "?uploadType=media",
+	}
+}
+
+// PublishRequest describes the request PublishExtension sends
+func (client *Client) PublishRequest(public bool) Request {
+	return Request{
+		Method: http.MethodPost,
+		URL:    "https://www.googleapis.com/chromewebstore/v1.1/items/" + client.Config.ExtID + "/publish?publishTarget=" + PublishTarget(public),
+	}
+}
+
+// PublishTarget is the api name of the audience an item is published to
+func PublishTarget(public bool) string {
+	if public {
+		return "default"
 	}
+	return "trustedTesters"
+}
 
+func (client *Client) doRequest(method, url string, body io.Reader, respData interface{}) error {
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return fmt.Errorf("constructing new request: %v", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+client.token)
-	resp, err := http.DefaultClient.Do(req)
+	return client.do(req, respData)
+}
+
+func (client *Client) do(req *http.Request, respData interface{}) error {
+	resp, bodyBytes, err := client.send(req)
 	if err != nil {
-		return fmt.Errorf("request: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("reading resp body: %v", err)
+	if err := parseAPIError(resp.StatusCode, bodyBytes); err != nil {
+		return err
 	}
-
-	if client.Config.Debug {
-		fmt.Println("RESPONSE:", string(bodyBytes))
+	if respData != nil {
+		if err := json.Unmarshal(bodyBytes, respData); err != nil {
+			return fmt.Errorf("unmarshalling resp: %v", err)
+		}
 	}
+	return nil
+}
 
-	storeErr := &webStoreErrorResp{}
-	if err := json.Unmarshal(bodyBytes, storeErr); err == nil {
-		if storeErr.Error.Code != 200 && (storeErr.Error.Status != "" || storeErr.Error.Message != "") {
-			return &storeErr.Error
+// send makes the request, retrying network failures and overloaded responses
+// when the body can be sent again, and logs each attempt at debug level.
+func (client *Client) send(req *http.Request) (*http.Response, []byte, error) {
+	var reqBody []byte
+	if req.GetBody != nil && har != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+		}
+	}
+	for retries := 0; ; retries++ {
+		started := time.Now()
+		resp, err := client.http.Do(req)
+		var bodyBytes []byte
+		if err == nil {
+			bodyBytes, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				err = fmt.Errorf("reading resp body: %w", err)
+			}
+		} else {
+			err = fmt.Errorf("request: %w", err)
+		}
+		latency := time.Since(started)
+		client.logRequest(req, resp, err, latency, retries)
+		if har != nil {
+			har.record(client, req, reqBody, resp, bodyBytes, started, latency)
+		}
+		if retries >= maxRetries || !retryable(req, resp, err) {
+			return resp, bodyBytes, err
+		}
+		wait := retryBackoff << retries
+		slog.Warn("retrying request", "method", req.Method, "url", client.redact(req.URL.String()), "wait", wait, "error", client.redact(fmt.Sprint(err)))
+		time.Sleep(wait)
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return resp, bodyBytes, err
+			}
 		}
 	}
+}
 
-	if respData != nil {
-		if err := json.Unmarshal(bodyBytes, respData); err != nil {
-			return fmt.Errorf("unmarshalling resp: %v", err)
+func (client *Client) logRequest(req *http.Request, resp *http.Response, err error, latency time.Duration, retries int) {
+	attrs := []any{
+		"method", req.Method,
+		"url", client.redact(req.URL.String()),
+		"latency", latency,
+		"retries", retries,
+	}
+	if client.Config.ExtID != "" {
+		attrs = append(attrs, "extension_id", client.Config.ExtID)
+	}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", client.redact(err.Error()))
+	}
+	slog.Debug("request", attrs...)
+}
+
+// retryable reports whether a failed attempt is worth repeating. A request with
+// a body can only be repeated if the body can be read again, which is not the
+// case for archive uploads.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	} else if err != nil {
+		// url.Error is a net.Error itself, only the error it wraps tells if the
+		// network failed
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
 		}
+		var netErr net.Error
+		return errors.As(err, &netErr) && !netErr.Timeout()
 	}
-	return nil
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// tokenField and formField match tokens and secrets in oauth requests and
+// responses, before the client knows them
+var (
+	tokenField = regexp.MustCompile(`"(access_token|id_token|refresh_token)"(\s*):(\s*)"[^"]*"`)
+	formField  = regexp.MustCompile(`\b(client_secret|refresh_token)=[^&\s]*`)
+)
+
+// redact hides config secrets and tokens in debug output
+func (client *Client) redact(text string) string {
+	text = redactTokens(text)
+	if client.token != "" {
+		text = strings.ReplaceAll(text, client.token, "[REDACTED]")
+	}
+	return client.Config.Redact(text)
 }
 
-func (err *webStoreError) Error() string {
-	return term.String(`{{printf "(%v)%v" .Code .Status | yellow}} {{.Message | bold}}`, err)
+func redactTokens(text string) string {
+	text = tokenField.ReplaceAllString(text, `"$1"$2:$3"[REDACTED]"`)
+	return formField.ReplaceAllString(text, `$1=[REDACTED]`)
 }
 
-// in the event that ItemError is available
-func (item WebStoreItem) Error() string {
-	return term.String(`{{.UploadState | yellow}} {{range .ItemError}}
-  Code: {{.Code}}
-  Detail: {{.Detail}}{{end}}`, item)
+// parseAPIError will find an error in the response, whether it is a webstore
+// error, an oauth error, or just a failed status code
+func parseAPIError(statusCode int, body []byte) error {
+	storeErr := &webStoreErrorResp{}
+	if err := json.Unmarshal(body, storeErr); err == nil {
+		if storeErr.Error.Code != 200 && (storeErr.Error.Status != "" || storeErr.Error.Message != "") {
+			apiErr := &APIError{
+				Code:    storeErr.Error.Code,
+				Status:  storeErr.Error.Status,
+				Message: storeErr.Error.Message,
+			}
+			for _, msg := range storeErr.Error.Errors {
+				apiErr.Reasons = append(apiErr.Reasons, msg.Reason)
+			}
+			return apiErr
+		}
+	}
+	if statusCode < 400 {
+		return nil
+	}
+	oauthErr := &oauthErrorResp{}
+	if err := json.Unmarshal(body, oauthErr); err == nil && oauthErr.Error != "" {
+		return &APIError{Code: statusCode, Status: oauthErr.Error, Message: oauthErr.Description}
+	}
+	return &APIError{Code: statusCode, Status: http.StatusText(statusCode)}
 }
@@ -0,0 +1,190 @@
+package gcloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRedact(t *testing.T) {
+	client := &Client{token: "access-123", Config: &Config{redact: []string{"client-secret"}}}
+	assert.Equal(t,
+		`{"access_token": "[REDACTED]", "refresh_token":"[REDACTED]", "expires_in": 3599}`,
+		client.redact(`{"access_token": "ya29.abc", "refresh_token":"1//xyz", "expires_in": 3599}`),
+	)
+	assert.Equal(t, "Bearer [REDACTED] [REDACTED]", client.redact("Bearer access-123 client-secret"))
+	assert.Equal(t, "client_id=id&client_secret=[REDACTED]&refresh_token=[REDACTED]", client.redact("client_id=id&client_secret=abc&refresh_token=1//xyz"))
+}
+
+func TestClientLogsAndRetries(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = 0
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "ya29.secret-token", "expires_in": 3599}`))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	recording := &HAR{}
+	SetHAR(recording)
+	defer SetHAR(nil)
+
+	client := &Client{http: server.Client(), Config: &Config{ExtID: "ext", redact: []string{"s3cret"}}}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/token", strings.NewReader("client_secret=s3cret"))
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp := gcloudTokenResp{}
+	require.Nil(t, client.do(req, &resp))
+	assert.Equal(t, "ya29.secret-token", resp.AccessToken)
+	assert.Equal(t, 2, attempts)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 3)
+	entry := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, float64(1), entry["retries"])
+	assert.Equal(t, "ext", entry["extension_id"])
+	assert.NotContains(t, logs.String(), "s3cret")
+
+	path := filepath.Join(t.TempDir(), "cws.har")
+	require.Nil(t, recording.WriteFile(path))
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"status": 503`)
+	assert.Contains(t, string(data), `client_secret=[REDACTED]`)
+	assert.NotContains(t, string(data), "s3cret")
+	assert.NotContains(t, string(data), "secret-token")
+}
+
+func TestRetryable(t *testing.T) {
+	file, err := os.Open("client.go")
+	require.Nil(t, err)
+	defer file.Close()
+	upload, _ := http.NewRequest(http.MethodPut, "https://example.com", file)
+	get, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable}
+
+	assert.True(t, retryable(get, unavailable, nil))
+	assert.False(t, retryable(get, &http.Response{StatusCode: http.StatusBadRequest}, nil))
+	assert.False(t, retryable(upload, unavailable, nil))
+}
+
+// replayClient authenticates a client against a cassette in testdata. Cassettes
+// can be recorded from real traffic with cws --record-cassette and are
+// redacted, the test config only needs the same client id and extension id.
+func replayClient(t *testing.T, name string) *Client {
+	cassette, err := NewCassette(filepath.Join("testdata", name+".json"), Replay)
+	require.Nil(t, err)
+	client := &Client{
+		Config: &Config{ExtID: "test-ext", ID: "test-client", Secret: "test-secret", RefreshToken: "test-token"},
+		http:   &http.Client{Transport: cassette},
+	}
+	require.Nil(t, client.authenticate())
+	assert.Equal(t, "[REDACTED]", client.token)
+	return client
+}
+
+func TestClientStatus(t *testing.T) {
+	client := replayClient(t, "status")
+	status, err := client.ExtensionStatus()
+	require.Nil(t, err)
+	assert.Equal(t, "1.2.0", status.Draft.CRXVersion)
+	assert.Equal(t, "1.1.0", status.Published.CRXVersion)
+	assert.Equal(t, "SUCCESS", status.Draft.UploadState)
+	assert.True(t, status.DraftDiffers())
+	status.Draft.CRXVersion = "1.1.0"
+	assert.False(t, status.DraftDiffers())
+	assert.False(t, WebStoreItemStatus{}.DraftDiffers())
+}
+
+func TestClientUpload(t *testing.T) {
+	client := replayClient(t, "upload")
+	item, err := client.UploadExtension(filepath.Join("testdata", "extension.zip"))
+	require.Nil(t, err)
+	assert.Equal(t, "SUCCESS", item.UploadState)
+
+	item, err = client.UploadExtension(filepath.Join("testdata", "extension.zip"))
+	uploadErr := &UploadError{}
+	require.ErrorAs(t, err, &uploadErr)
+	assert.Equal(t, "FAILURE", uploadErr.State)
+	assert.Equal(t, "PKG_INVALID_VERSION_NUMBER", item.ItemError[0].Code)
+	assert.ErrorIs(t, err, &UploadError{State: "FAILURE"})
+}
+
+func TestClientPublish(t *testing.T) {
+	client := replayClient(t, "publish")
+	item, err := client.PublishExtension(false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"OK"}, item.Status)
+
+	_, err = client.PublishExtension(true)
+	publishErr := &PublishError{}
+	require.ErrorAs(t, err, &publishErr)
+	assert.Equal(t, []string{"ITEM_PENDING_REVIEW"}, publishErr.Status)
+
+	_, err = client.PublishExtension(true)
+	assert.ErrorIs(t, err, &APIError{Code: 403, Status: "PERMISSION_DENIED"})
+
+	_, err = client.PublishExtension(true)
+	assert.ErrorIs(t, err, ErrNoInteraction)
+}
+
+func TestCassetteRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(`{"access_token": "ya29.secret-token", "id": "` + r.URL.Query().Get("projection") + `"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := NewCassette(path, Record)
+	require.Nil(t, err)
+	recorder.Redact = func(text string) string { return strings.ReplaceAll(text, "DRAFT", "[REDACTED]") }
+	client := &http.Client{Transport: recorder}
+	resp, err := client.Post(server.URL+"/items?projection=DRAFT", "text/plain", strings.NewReader("client_secret=s3cret"))
+	require.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "ya29.secret-token")
+	require.Nil(t, recorder.Save())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.NotContains(t, string(data), "secret-token")
+	assert.NotContains(t, string(data), "s3cret")
+	assert.NotContains(t, string(data), "session=abc")
+	assert.NotContains(t, string(data), `"id": "DRAFT"`)
+
+	player, err := NewCassette(path, Replay)
+	require.Nil(t, err)
+	client = &http.Client{Transport: player}
+	resp, err = client.Post("https://www.googleapis.com/items?projection=DRAFT", "text/plain", strings.NewReader("client_secret=other"))
+	require.Nil(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, `{"access_token": "[REDACTED]", "id": "[REDACTED]"}`, string(body))
+
+	_, err = client.Get("https://www.googleapis.com/items?projection=PUBLISHED")
+	assert.ErrorIs(t, err, ErrNoInteraction)
+}
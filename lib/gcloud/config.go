@@ -5,43 +5,584 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
-	"github.com/imdario/mergo"
 	"github.com/sethvargo/go-envconfig"
+
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/notify"
+	"github.com/tanema/cws/lib/secrets"
 )
 
-type Config struct {
-	Debug        bool   `json:"debug,omitempty" env:"CWS_DEBUG"`
-	ExtID        string `json:"extension_id" env:"CWS_EXTENSION_ID"`
-	ID           string `json:"client_id" env:"CWS_CLIENT_ID"`
-	Secret       string `json:"client_secret" env:"CWS_CLIENT_SECRET"`
-	RefreshToken string `json:"refresh_token" env:"CWS_REFRESH_TOKEN"`
-}
+// ConfigFileName is the name of the project config that is discovered by
+// walking up from the working directory
+const ConfigFileName = "chrome_webstore.json"
+
+type (
+	// Config is the effective configuration after merging the user config, the
+	// project config, the selected profile and the environment.
+	Config struct {
+		// Debug logs every api request, like --log-level debug
+		Debug        bool              `json:"debug,omitempty" env:"CWS_DEBUG"`
+		ExtID        string            `json:"extension_id" env:"CWS_EXTENSION_ID" ref:"true"`
+		ID           string            `json:"client_id" env:"CWS_CLIENT_ID" ref:"true"`
+		Secret       string            `json:"client_secret" env:"CWS_CLIENT_SECRET" secret:"true"`
+		RefreshToken string            `json:"refresh_token" env:"CWS_REFRESH_TOKEN" secret:"true"`
+		Extensions   []Extension       `json:"extensions,omitempty"`
+		Profiles     map[string]Config `json:"profiles,omitempty"`
+		Hooks        Hooks             `json:"hooks,omitempty"`
+		// Notifiers are sent release events like uploads and rejections
+		Notifiers []notify.Notifier `json:"notifiers,omitempty"`
+		// Ledger is the file releases are recorded in, defaults to
+		// $XDG_STATE_HOME/cws/ledger.jsonl
+		Ledger string `json:"ledger,omitempty" env:"CWS_LEDGER"`
+		// Artifacts is where uploaded archives are kept
+		Artifacts Artifacts `json:"artifacts,omitempty"`
+		// Lint configures the findings of cws lint
+		Lint Lint `json:"lint,omitempty"`
+		// Budgets fail a build whose archive is too large
+		Budgets Budgets `json:"budgets,omitempty"`
+
+		// HTTPSProxy and NoProxy override HTTPS_PROXY and NO_PROXY for api requests
+		HTTPSProxy string `json:"https_proxy,omitempty" env:"CWS_HTTPS_PROXY"`
+		NoProxy    string `json:"no_proxy,omitempty" env:"CWS_NO_PROXY"`
+		// CAFiles are PEM bundles trusted on top of the system roots, like for a
+		// proxy that intercepts TLS
+		CAFiles []string `json:"ca_files,omitempty" env:"CWS_CA_FILES"`
+		// ClientCert and ClientKey are a PEM certificate and key for mTLS
+		ClientCert string `json:"client_cert,omitempty" env:"CWS_CLIENT_CERT"`
+		ClientKey  string `json:"client_key,omitempty" env:"CWS_CLIENT_KEY"`
+
+		// Files are the config files that were loaded, lowest precedence first
+		Files []string `json:"-"`
+		// Profile is the name of the selected profile
+		Profile string `json:"-"`
+		// Sources records where each value came from, keyed by json name
+		Sources map[string]string `json:"-"`
+
+		// redact are secret and resolved values that must not be printed
+		redact []string
+		// httpClient is shared by every client made from the config
+		httpClient *http.Client
+	}
+	// Extension is a named extension in a config that manages several extensions.
+	// Credentials that are not set fall back to the ones shared in the Config.
+	Extension struct {
+		Name          string `json:"name"`
+		ExtID         string `json:"extension_id" ref:"true"`
+		Source        string `json:"source,omitempty"`
+		ManifestPatch string `json:"manifest_patch,omitempty"`
+		PublishTarget string `json:"publish_target,omitempty"`
+		ID            string `json:"client_id,omitempty" ref:"true"`
+		Secret        string `json:"client_secret,omitempty" secret:"true"`
+		RefreshToken  string `json:"refresh_token,omitempty" secret:"true"`
+		Hooks         Hooks  `json:"hooks,omitempty"`
+	}
+	// Hooks are shell commands that run around the steps of a release. A hook
+	// that fails aborts the release, and onfailure runs when any step fails.
+	Hooks struct {
+		Prebuild    string `json:"prebuild,omitempty"`
+		Postarchive string `json:"postarchive,omitempty"`
+		Preupload   string `json:"preupload,omitempty"`
+		Postupload  string `json:"postupload,omitempty"`
+		Postpublish string `json:"postpublish,omitempty"`
+		OnFailure   string `json:"onfailure,omitempty"`
+		// Timeout limits how long each hook can run, like 5m
+		Timeout string `json:"timeout,omitempty"`
+	}
+	// Artifacts configures the store of uploaded archives and how long they
+	// are kept
+	Artifacts struct {
+		// Dir defaults to $XDG_CACHE_HOME/cws/artifacts
+		Dir string `json:"dir,omitempty" env:"CWS_ARTIFACTS_DIR"`
+		// Keep is how many archives of each extension are kept
+		Keep int `json:"keep,omitempty"`
+		// MaxAge removes archives older than it, like 720h
+		MaxAge string `json:"max_age,omitempty"`
+	}
+	// Lint configures the findings of cws lint
+	Lint struct {
+		// Suppress are findings that are not reported, by rule like "eval" or by
+		// rule and path like "eval:vendor/*"
+		Suppress []string `json:"suppress,omitempty" env:"CWS_LINT_SUPPRESS"`
+	}
+	// Budgets limit the size of the archive that is built, a release that is
+	// over a budget fails before it is uploaded
+	Budgets struct {
+		// MaxSize is the largest the archive can be, like 10MB
+		MaxSize string `json:"max_size,omitempty"`
+		// MaxFileSize is the largest a file can be before compression
+		MaxFileSize string `json:"max_file_size,omitempty"`
+		// MaxGrowth is how much larger the archive can be than the last kept
+		// artifact of the extension, like 500KB or 10%
+		MaxGrowth string `json:"max_growth,omitempty"`
+	}
+	// LoadOptions controls where the config is loaded from
+	LoadOptions struct {
+		// Path is an explicit config file, when empty the project config is
+		// discovered by walking up from Dir
+		Path string
+		// Dir is where discovery starts, defaults to the working directory
+		Dir string
+		// Profile selects a named profile, defaults to CWS_PROFILE
+		Profile string
+		// Secrets resolves references to stored secrets, each store is opened
+		// once. Defaults to stores opened without options.
+		Secrets *secrets.Stores
+		// AllowMissing skips an explicit Path that does not exist and a Profile
+		// that is not defined, for commands that create them
+		AllowMissing bool
+	}
+	// ConfigValue is a single value of the effective config and where it came from
+	ConfigValue struct {
+		Key    string
+		Value  string
+		Source string
+		Secret bool
+	}
+)
+
+// LoadConfig merges, from lowest to highest precedence, the user config in
+// $XDG_CONFIG_HOME/cws, the project config, and the CWS_* environment
+// variables. A selected profile in either config file overrides the values of
+// the file it is defined in.
+func LoadConfig(opts LoadOptions) (*Config, error) {
+	if opts.Profile == "" {
+		opts.Profile = os.Getenv("CWS_PROFILE")
+	}
+	conf := &Config{Profile: opts.Profile, Sources: map[string]string{}}
+	if userPath := UserConfigPath(); userPath != "" {
+		if _, err := os.Stat(userPath); err == nil {
+			conf.Files = append(conf.Files, userPath)
+		}
+	}
+	if opts.Path != "" {
+		if _, err := os.Stat(opts.Path); err == nil {
+			conf.Files = append(conf.Files, opts.Path)
+		} else if !opts.AllowMissing || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not read config: %w", err)
+		}
+	} else {
+		if opts.Dir == "" {
+			opts.Dir, _ = os.Getwd()
+		}
+		if projectPath := FindConfig(opts.Dir); projectPath != "" {
+			conf.Files = append(conf.Files, projectPath)
+		}
+	}
+
+	profileFound := opts.Profile == ""
+	for _, path := range conf.Files {
+		fileConf, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		conf.merge(fileConf, path)
+		if profile, ok := fileConf.Profiles[opts.Profile]; ok && opts.Profile != "" {
+			conf.merge(profile, fmt.Sprintf("%v (profile %v)", path, opts.Profile))
+			profileFound = true
+		}
+	}
+	if !profileFound && !opts.AllowMissing {
+		return nil, fmt.Errorf("profile %q was not found in %v", opts.Profile, strings.Join(conf.Files, ", "))
+	}
 
-func loadConfig(configPath string) (*Config, error) {
 	envConf := Config{}
 	if err := envconfig.Process(context.Background(), &envConf); err != nil {
 		return nil, err
 	}
-	if _, err := os.Stat(configPath); err == nil {
-		data, err := ioutil.ReadFile(configPath)
+	conf.merge(envConf, "env")
+	if opts.Secrets == nil {
+		opts.Secrets = secrets.NewStores(secrets.Options{})
+	}
+	if err := conf.resolveRefs(opts.Secrets); err != nil {
+		return nil, err
+	}
+	if _, err := conf.HTTPClient(); err != nil {
+		return nil, err
+	}
+	return conf, conf.validate()
+}
+
+// UserConfigPath is the path of the user level config in $XDG_CONFIG_HOME/cws
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
 		if err != nil {
-			return nil, err
+			return ""
 		}
-		fileConf := Config{}
-		if err := json.Unmarshal(data, &fileConf); err != nil {
-			return nil, err
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "cws", "config.json")
+}
+
+// FindConfig walks up from dir looking for a chrome_webstore.json. It stops at
+// the root of the repository so that a config outside of it is not picked up.
+func FindConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		} else if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
 		}
-		if err := mergo.Merge(&envConf, fileConf); err != nil {
-			return nil, err
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func readConfigFile(path string) (Config, error) {
+	fileConf := Config{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fileConf, err
+	}
+	if err := json.Unmarshal(data, &fileConf); err != nil {
+		return fileConf, fmt.Errorf("parsing config %v: %w", path, err)
+	}
+	// paths are relative to the config so that it works from any directory
+	resolvePaths(&fileConf, filepath.Dir(path))
+	for name, profile := range fileConf.Profiles {
+		resolvePaths(&profile, filepath.Dir(path))
+		fileConf.Profiles[name] = profile
+	}
+	return fileConf, nil
+}
+
+func resolvePaths(conf *Config, dir string) {
+	for i := range conf.Extensions {
+		conf.Extensions[i].Source = resolvePath(dir, conf.Extensions[i].Source)
+	}
+	for i := range conf.CAFiles {
+		conf.CAFiles[i] = resolvePath(dir, conf.CAFiles[i])
+	}
+	conf.ClientCert = resolvePath(dir, conf.ClientCert)
+	conf.ClientKey = resolvePath(dir, conf.ClientKey)
+	conf.Ledger = resolvePath(dir, conf.Ledger)
+	conf.Artifacts.Dir = resolvePath(dir, conf.Artifacts.Dir)
+}
+
+// resolvePath joins relative paths to dir, references like env:NAME are left
+// to be resolved later
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) || strings.Contains(path, ":") {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// resolveRefs replaces references, like keyring:cws/prod/client_secret or
+// env:CLIENT_SECRET, with the value they point to
+func (conf *Config) resolveRefs(stores *secrets.Stores) error {
+	if err := conf.resolveFields(reflect.ValueOf(conf).Elem(), conf.Sources, stores); err != nil {
+		return err
+	}
+	for i := range conf.Extensions {
+		if err := conf.resolveFields(reflect.ValueOf(&conf.Extensions[i]).Elem(), nil, stores); err != nil {
+			return fmt.Errorf("extension %v: %w", conf.Extensions[i].Name, err)
+		}
+	}
+	for i, n := range conf.Notifiers {
+		if err := conf.resolveFields(reflect.ValueOf(&conf.Notifiers[i]).Elem(), nil, stores); err != nil {
+			return fmt.Errorf("notifier %v: %w", n.Name, err)
+		}
+		// headers often carry a token so they can be references too
+		for key, ref := range n.Headers {
+			value, err := stores.Resolve(ref)
+			if err != nil {
+				return fmt.Errorf("notifier %v: %w", n.Name, err)
+			} else if value != ref && len(value) >= minRedactLen {
+				conf.redact = append(conf.redact, value)
+			}
+			n.Headers[key] = value
+		}
+	}
+	return nil
+}
+
+func (conf *Config) resolveFields(val reflect.Value, sources map[string]string, stores *secrets.Stores) error {
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		if field.Type.Kind() != reflect.String || jsonKey(field) == "" || !isRefField(field) {
+			continue
+		}
+		ref := val.Field(i).String()
+		value, err := stores.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		if len(value) >= minRedactLen && (value != ref || field.Tag.Get("secret") == "true") {
+			conf.redact = append(conf.redact, value)
+		}
+		if value == ref {
+			continue
+		}
+		val.Field(i).SetString(value)
+		if key := jsonKey(field); sources != nil && sources[key] != "" {
+			sources[key] += " via " + ref
+		}
+	}
+	return nil
+}
+
+// isRefField is true for the fields that can be references, secrets and the
+// ids that go with them. Other values, like sources and templates, are used as
+// they are so that a config in a cloned repo can not run commands.
+func isRefField(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true" || field.Tag.Get("ref") == "true"
+}
+
+// minRedactLen keeps short values, which are not real secrets, from redacting
+// parts of unrelated words
+const minRedactLen = 8
+
+// Redact hides secret and resolved values of the config in text so that it
+// can be printed in debug output
+func (conf *Config) Redact(text string) string {
+	for _, value := range conf.redact {
+		text = strings.ReplaceAll(text, value, "[REDACTED]")
+	}
+	return text
+}
+
+// merge sets every value that is set in other, and records the source of it
+func (conf *Config) merge(other Config, source string) {
+	mergeFields(reflect.ValueOf(conf).Elem(), reflect.ValueOf(other), "", source, conf.Sources)
+}
+
+// mergeFields merges nested structs, like hooks, field by field so that a
+// profile can override a single hook
+func mergeFields(dst, src reflect.Value, prefix, source string, sources map[string]string) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		key := jsonKey(field)
+		if key == "" || key == "profiles" || src.Field(i).IsZero() {
+			continue
+		} else if field.Type.Kind() == reflect.Struct {
+			mergeFields(dst.Field(i), src.Field(i), prefix+key+".", source, sources)
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+		if source == "env" {
+			sources[prefix+key] = "env " + field.Tag.Get("env")
+		} else {
+			sources[prefix+key] = source
+		}
+	}
+}
+
+// Values lists every value of the effective config with where it came from.
+// Extension values are keyed by extensions.<name>.<key> and notifier values by
+// notifiers.<name>.<key>
+func (conf *Config) Values() []ConfigValue {
+	values := structValues(reflect.ValueOf(*conf), "", conf.Sources, "")
+	for _, ext := range conf.Extensions {
+		prefix := "extensions." + ext.Name + "."
+		values = append(values, structValues(reflect.ValueOf(ext), prefix, nil, conf.Sources["extensions"])...)
+	}
+	for _, n := range conf.Notifiers {
+		prefix := "notifiers." + n.Name + "."
+		values = append(values, structValues(reflect.ValueOf(n), prefix, nil, conf.Sources["notifiers"])...)
+	}
+	return values
+}
+
+func structValues(val reflect.Value, prefix string, sources map[string]string, source string) []ConfigValue {
+	values := []ConfigValue{}
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		key := jsonKey(field)
+		kind := field.Type.Kind()
+		if key == "" || kind == reflect.Map || val.Field(i).IsZero() {
+			continue
+		} else if kind == reflect.Struct {
+			values = append(values, structValues(val.Field(i), prefix+key+".", sources, source)...)
+			continue
+		}
+		text := fmt.Sprintf("%v", val.Field(i).Interface())
+		if list, ok := val.Field(i).Interface().([]string); ok {
+			text = strings.Join(list, ",")
+		} else if kind == reflect.Slice {
+			continue
+		}
+		value := ConfigValue{
+			Key:    prefix + key,
+			Value:  text,
+			Source: source,
+			Secret: field.Tag.Get("secret") == "true",
+		}
+		if sources != nil {
+			value.Source = sources[value.Key]
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func jsonKey(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("json"), ",")[0]
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// ForExtension creates the config for a single named extension, falling back
+// to the shared credentials where the extension does not have its own.
+func (conf *Config) ForExtension(ext Extension) *Config {
+	extConf := &Config{
+		Debug:        conf.Debug,
+		ExtID:        ext.ExtID,
+		ID:           ext.ID,
+		Secret:       ext.Secret,
+		RefreshToken: ext.RefreshToken,
+		HTTPSProxy:   conf.HTTPSProxy,
+		NoProxy:      conf.NoProxy,
+		CAFiles:      conf.CAFiles,
+		ClientCert:   conf.ClientCert,
+		ClientKey:    conf.ClientKey,
+		Hooks:        conf.Hooks,
+		Notifiers:    conf.Notifiers,
+		Ledger:       conf.Ledger,
+		Artifacts:    conf.Artifacts,
+		Lint:         conf.Lint,
+		Budgets:      conf.Budgets,
+		redact:       conf.redact,
+		httpClient:   conf.httpClient,
+	}
+	mergeFields(reflect.ValueOf(&extConf.Hooks).Elem(), reflect.ValueOf(ext.Hooks), "hooks.", "", map[string]string{})
+	if extConf.ID == "" {
+		extConf.ID = conf.ID
+	}
+	if extConf.Secret == "" {
+		extConf.Secret = conf.Secret
+	}
+	if extConf.RefreshToken == "" {
+		extConf.RefreshToken = conf.RefreshToken
+	}
+	return extConf
+}
+
+// Select finds the configured extensions with the given names, in the order of
+// the names. If no names are given all extensions are returned.
+func (conf *Config) Select(names []string) ([]Extension, error) {
+	if len(names) == 0 {
+		return conf.Extensions, nil
+	}
+	selected := []Extension{}
+	for _, name := range names {
+		found := false
+		for _, ext := range conf.Extensions {
+			if ext.Name == name {
+				selected = append(selected, ext)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no extension named %q in the config", name)
 		}
 	}
-	return &envConf, envConf.validate()
+	return selected, nil
+}
+
+// Commands lists the hook commands keyed by hook name
+func (hooks Hooks) Commands() map[string]string {
+	commands := map[string]string{}
+	val := reflect.ValueOf(hooks)
+	for i := 0; i < val.NumField(); i++ {
+		if key := jsonKey(val.Type().Field(i)); key != "timeout" && val.Field(i).String() != "" {
+			commands[key] = val.Field(i).String()
+		}
+	}
+	return commands
+}
+
+// Retention is the policy that decides which archives are pruned, the newest
+// archive of an extension is always kept
+func (arts Artifacts) Retention() (artifacts.Retention, error) {
+	policy := artifacts.Retention{Keep: arts.Keep}
+	if arts.Keep < 0 {
+		return policy, fmt.Errorf("Configuration has an invalid artifacts.keep %v, expected 0 or more", arts.Keep)
+	} else if arts.MaxAge == "" {
+		return policy, nil
+	}
+	maxAge, err := time.ParseDuration(arts.MaxAge)
+	if err != nil || maxAge <= 0 {
+		return policy, fmt.Errorf("Configuration has an invalid artifacts.max_age %q, expected a duration like 720h", arts.MaxAge)
+	}
+	policy.MaxAge = maxAge
+	return policy, nil
+}
+
+// TimeoutDuration parses the hook timeout, zero means the default
+func (hooks Hooks) TimeoutDuration() (time.Duration, error) {
+	if hooks.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(hooks.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("Configuration has an invalid hooks.timeout %q, expected a duration like 5m", hooks.Timeout)
+	}
+	return timeout, nil
 }
 
 func (conf *Config) validate() error {
+	if err := conf.validateNotifiers(); err != nil {
+		return err
+	} else if _, err := conf.Artifacts.Retention(); err != nil {
+		return err
+	}
+	if len(conf.Extensions) == 0 {
+		return conf.validateExtension()
+	}
+	names := map[string]bool{}
+	for _, ext := range conf.Extensions {
+		if ext.Name == "" {
+			return fmt.Errorf("Configuration has an extension without a name, all extensions need a name")
+		} else if names[ext.Name] {
+			return fmt.Errorf("Configuration has more than one extension named %q", ext.Name)
+		} else if ext.PublishTarget != "" && ext.PublishTarget != PublishTarget(true) && ext.PublishTarget != PublishTarget(false) {
+			return fmt.Errorf("Configuration for %v has an unknown publish_target %q, expected %v or %v", ext.Name, ext.PublishTarget, PublishTarget(true), PublishTarget(false))
+		}
+		names[ext.Name] = true
+		if err := conf.ForExtension(ext).validateExtension(); err != nil {
+			return fmt.Errorf("%v: %w", ext.Name, err)
+		}
+	}
+	return nil
+}
+
+func (conf *Config) validateNotifiers() error {
+	names := map[string]bool{}
+	for _, n := range conf.Notifiers {
+		if err := n.Validate(); err != nil {
+			return fmt.Errorf("Configuration has an invalid notifier: %w", err)
+		} else if names[n.Name] {
+			return fmt.Errorf("Configuration has more than one notifier named %q", n.Name)
+		}
+		names[n.Name] = true
+	}
+	return nil
+}
+
+func (conf *Config) validateExtension() error {
+	if _, err := conf.Hooks.TimeoutDuration(); err != nil {
+		return err
+	}
 	missingVals := []string{}
 	if conf.ExtID == "" {
 		missingVals = append(missingVals, "extension_id")
@@ -50,7 +591,7 @@ func (conf *Config) validate() error {
 		missingVals = append(missingVals, "client_id")
 	}
 	if conf.Secret == "" {
-		missingVals = append(missingVals, "secret_id")
+		missingVals = append(missingVals, "client_secret")
 	}
 	if conf.RefreshToken == "" {
 		missingVals = append(missingVals, "refresh_token")
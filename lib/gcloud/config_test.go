@@ -0,0 +1,336 @@
+package gcloud
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+
+	"github.com/tanema/cws/lib/artifacts"
+	"github.com/tanema/cws/lib/secrets"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.Nil(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func clearEnv(t *testing.T) {
+	for _, key := range []string{"CWS_DEBUG", "CWS_EXTENSION_ID", "CWS_CLIENT_ID", "CWS_CLIENT_SECRET", "CWS_REFRESH_TOKEN", "CWS_PROFILE", "CWS_SECRETS_KEY", "CWS_SECRETS_PASSPHRASE", "CWS_SECRETS_FILE", "CWS_LEDGER", "CWS_ARTIFACTS_DIR", "CWS_LINT_SUPPRESS"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	nested := filepath.Join(repo, "packages", "ext")
+	require.Nil(t, os.MkdirAll(filepath.Join(repo, ".git"), 0700))
+	require.Nil(t, os.MkdirAll(nested, 0700))
+
+	writeFile(t, filepath.Join(root, ConfigFileName), `{}`)
+	assert.Equal(t, "", FindConfig(nested))
+
+	writeFile(t, filepath.Join(repo, ConfigFileName), `{}`)
+	assert.Equal(t, filepath.Join(repo, ConfigFileName), FindConfig(nested))
+}
+
+func TestLoadConfig(t *testing.T) {
+	clearEnv(t)
+	userPath := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "cws", "config.json")
+	writeFile(t, userPath, `{"client_id": "user-client", "client_secret": "user-secret", "refresh_token": "user-token"}`)
+	dir := t.TempDir()
+	projectPath := filepath.Join(dir, ConfigFileName)
+	writeFile(t, projectPath, `{
+		"extension_id": "dev-ext",
+		"profiles": {"prod": {"extension_id": "prod-ext", "client_secret": "prod-secret"}}
+	}`)
+	t.Setenv("CWS_REFRESH_TOKEN", "env-token")
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, []string{userPath, projectPath}, conf.Files)
+	assert.Equal(t, "dev-ext", conf.ExtID)
+	assert.Equal(t, "user-secret", conf.Secret)
+	assert.Equal(t, "env-token", conf.RefreshToken)
+	assert.Equal(t, map[string]string{
+		"extension_id":  projectPath,
+		"client_id":     userPath,
+		"client_secret": userPath,
+		"refresh_token": "env CWS_REFRESH_TOKEN",
+	}, conf.Sources)
+
+	t.Setenv("CWS_PROFILE", "prod")
+	conf, err = LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, "prod", conf.Profile)
+	assert.Equal(t, "prod-ext", conf.ExtID)
+	assert.Equal(t, "prod-secret", conf.Secret)
+	assert.Equal(t, projectPath+" (profile prod)", conf.Sources["client_secret"])
+
+	_, err = LoadConfig(LoadOptions{Dir: dir, Profile: "staging"})
+	assert.EqualError(t, err, `profile "staging" was not found in `+userPath+", "+projectPath)
+}
+
+func TestLoadConfigExplicitPath(t *testing.T) {
+	clearEnv(t)
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	_, err := LoadConfig(LoadOptions{Path: missing})
+	assert.NotNil(t, err)
+	conf, _ := LoadConfig(LoadOptions{Path: missing, Profile: "new", AllowMissing: true})
+	require.NotNil(t, conf)
+	assert.Empty(t, conf.Files)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom.json")
+	writeFile(t, path, `{"client_id": "id", "client_secret": "secret", "refresh_token": "token", "extensions": [{"name": "a", "extension_id": "ext-a", "source": "./a"}]}`)
+	conf, err = LoadConfig(LoadOptions{Path: path})
+	require.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "a"), conf.Extensions[0].Source)
+	assert.Contains(t, conf.Values(), ConfigValue{Key: "extensions.a.extension_id", Value: "ext-a", Source: path})
+	assert.Contains(t, conf.Values(), ConfigValue{Key: "client_secret", Value: "secret", Source: path, Secret: true})
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	clearEnv(t)
+	conf, err := LoadConfig(LoadOptions{Dir: t.TempDir()})
+	assert.NotNil(t, conf)
+	assert.EqualError(t, err, "Configuration is missing extension_id, client_id, client_secret, refresh_token which are required for cws to run")
+}
+
+func TestLoadConfigSecretRefs(t *testing.T) {
+	clearEnv(t)
+	keyring.MockInit()
+	require.Nil(t, keyring.Set("cws", "prod/client_secret", "keyring-secret"))
+	prompts := 0
+	opts := secrets.Options{
+		File: filepath.Join(t.TempDir(), "secrets.age"),
+		Passphrase: func() (string, error) {
+			prompts++
+			return "hunter2", nil
+		},
+	}
+	_, err := secrets.Save(secrets.KindAge, "beta/refresh_token", "file-token", opts)
+	require.Nil(t, err)
+	_, err = secrets.Save(secrets.KindAge, "prod/refresh_token", "prod-token", opts)
+	require.Nil(t, err)
+	prompts = 0
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	writeFile(t, path, `{
+		"client_id": "client",
+		"client_secret": "keyring:cws/prod/client_secret",
+		"refresh_token": "age:prod/refresh_token",
+		"extensions": [{"name": "beta", "extension_id": "beta-ext", "refresh_token": "age:beta/refresh_token"}]
+	}`)
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir, Secrets: secrets.NewStores(opts)})
+	require.Nil(t, err)
+	assert.Equal(t, 1, prompts)
+	assert.Equal(t, "keyring-secret", conf.Secret)
+	assert.Equal(t, "prod-token", conf.RefreshToken)
+	assert.Equal(t, path+" via keyring:cws/prod/client_secret", conf.Sources["client_secret"])
+	assert.Equal(t, "file-token", conf.Extensions[0].RefreshToken)
+
+	writeFile(t, path, `{"client_secret": "keyring:cws/missing"}`)
+	_, err = LoadConfig(LoadOptions{Dir: dir, Secrets: secrets.NewStores(opts)})
+	assert.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestLoadConfigExternalRefs(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CI_CLIENT_SECRET", "env-secret")
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token")
+	writeFile(t, tokenPath, "file-token\n")
+	path := filepath.Join(dir, ConfigFileName)
+	writeFile(t, path, `{
+		"extension_id": "exec:echo exec-ext",
+		"client_id": "client",
+		"client_secret": "env:CI_CLIENT_SECRET",
+		"refresh_token": "file:`+tokenPath+`"
+	}`)
+	t.Setenv("CWS_CLIENT_ID", "env:CI_CLIENT_ID")
+	t.Setenv("CI_CLIENT_ID", "env-client")
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, "exec-ext", conf.ExtID)
+	assert.Equal(t, "env-client", conf.ID)
+	assert.Equal(t, "env-secret", conf.Secret)
+	assert.Equal(t, "file-token", conf.RefreshToken)
+	assert.Equal(t, "env CWS_CLIENT_ID via env:CI_CLIENT_ID", conf.Sources["client_id"])
+
+	assert.Equal(t,
+		"client_secret=[REDACTED]&refresh_token=[REDACTED]&id=[REDACTED]",
+		conf.Redact("client_secret=env-secret&refresh_token=file-token&id=exec-ext"),
+	)
+	assert.Equal(t, "[REDACTED]", conf.ForExtension(Extension{}).Redact("env-secret"))
+}
+
+func TestLoadConfigOnlyResolvesRefFields(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	writeFile(t, filepath.Join(dir, ConfigFileName), `{
+		"client_id": "client", "client_secret": "secret", "refresh_token": "token",
+		"extensions": [{"name": "main", "extension_id": "main-ext", "source": "exec:touch `+marker+`", "manifest_patch": "env:HOME"}],
+		"notifiers": [{"name": "chat", "type": "webhook", "url": "https://example.com", "template": "file:/etc/passwd"}]
+	}`)
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, "env:HOME", conf.Extensions[0].ManifestPatch)
+	assert.Equal(t, "file:/etc/passwd", conf.Notifiers[0].Template)
+	_, err = os.Stat(marker)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadConfigHooks(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	writeFile(t, path, `{
+		"client_id": "client", "client_secret": "secret", "refresh_token": "token",
+		"hooks": {"prebuild": "npm run build", "postpublish": "git tag $CWS_VERSION", "timeout": "2m"},
+		"profiles": {"ci": {"hooks": {"prebuild": "npm ci && npm run build"}}},
+		"extensions": [
+			{"name": "main", "extension_id": "main-ext"},
+			{"name": "beta", "extension_id": "beta-ext", "hooks": {"postpublish": "echo beta"}}
+		]
+	}`)
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir, Profile: "ci"})
+	require.Nil(t, err)
+	assert.Equal(t, "npm ci && npm run build", conf.Hooks.Prebuild)
+	assert.Equal(t, "git tag $CWS_VERSION", conf.Hooks.Postpublish)
+	assert.Equal(t, path+" (profile ci)", conf.Sources["hooks.prebuild"])
+	assert.Equal(t, path, conf.Sources["hooks.postpublish"])
+	timeout, err := conf.Hooks.TimeoutDuration()
+	require.Nil(t, err)
+	assert.Equal(t, 2*time.Minute, timeout)
+
+	beta := conf.ForExtension(conf.Extensions[1])
+	assert.Equal(t, map[string]string{"prebuild": "npm ci && npm run build", "postpublish": "echo beta"}, beta.Hooks.Commands())
+	assert.Equal(t, "git tag $CWS_VERSION", conf.ForExtension(conf.Extensions[0]).Hooks.Postpublish)
+
+	found := false
+	for _, val := range conf.Values() {
+		if val.Key == "hooks.prebuild" {
+			found = true
+			assert.Equal(t, "npm ci && npm run build", val.Value)
+			assert.Equal(t, path+" (profile ci)", val.Source)
+		}
+	}
+	assert.True(t, found)
+
+	writeFile(t, path, `{"client_id": "client", "client_secret": "secret", "refresh_token": "token", "extension_id": "ext", "hooks": {"timeout": "soon"}}`)
+	_, err = LoadConfig(LoadOptions{Dir: dir})
+	assert.ErrorContains(t, err, "invalid hooks.timeout")
+}
+
+func TestLoadConfigNotifiers(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	t.Setenv("SLACK_WEBHOOK", "https://hooks.slack.com/services/T0/B0/secret")
+	t.Setenv("BOT_TOKEN", "Bearer bot-token")
+	writeFile(t, path, `{
+		"client_id": "client", "client_secret": "secret", "refresh_token": "token",
+		"notifiers": [
+			{"name": "team", "type": "slack", "url": "env:SLACK_WEBHOOK", "events": ["publish", "rejected"],
+			 "headers": {"Authorization": "env:BOT_TOKEN"}}
+		],
+		"extensions": [{"name": "main", "extension_id": "main-ext"}]
+	}`)
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	require.Len(t, conf.Notifiers, 1)
+	assert.Equal(t, "https://hooks.slack.com/services/T0/B0/secret", conf.Notifiers[0].URL)
+	assert.Equal(t, "Bearer bot-token", conf.Notifiers[0].Headers["Authorization"])
+	assert.Equal(t, conf.Notifiers, conf.ForExtension(conf.Extensions[0]).Notifiers)
+	assert.Equal(t, "posting to [REDACTED]", conf.Redact("posting to https://hooks.slack.com/services/T0/B0/secret"))
+
+	found := false
+	for _, val := range conf.Values() {
+		if val.Key == "notifiers.team.url" {
+			found = true
+			assert.True(t, val.Secret)
+			assert.Equal(t, path, val.Source)
+		}
+	}
+	assert.True(t, found)
+
+	writeFile(t, path, `{"client_id": "client", "client_secret": "secret", "refresh_token": "token", "extension_id": "ext",
+		"notifiers": [{"name": "team", "type": "email", "url": "x"}]}`)
+	_, err = LoadConfig(LoadOptions{Dir: dir})
+	assert.ErrorContains(t, err, `unknown type "email"`)
+
+	writeFile(t, path, `{"client_id": "client", "client_secret": "secret", "refresh_token": "token", "extension_id": "ext",
+		"notifiers": [{"name": "team", "type": "slack", "url": "x"}, {"name": "team", "type": "teams", "url": "y"}]}`)
+	_, err = LoadConfig(LoadOptions{Dir: dir})
+	assert.ErrorContains(t, err, `more than one notifier named "team"`)
+}
+
+func TestLoadConfigLedgerAndArtifacts(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	writeFile(t, path, `{"client_id": "client", "client_secret": "secret", "refresh_token": "token",
+		"ledger": ".cws/ledger.jsonl", "artifacts": {"dir": ".cws/artifacts"}, "extensions": [{"name": "main", "extension_id": "main-ext"}]}`)
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, ".cws", "ledger.jsonl"), conf.Ledger)
+	assert.Equal(t, filepath.Join(dir, ".cws", "artifacts"), conf.Artifacts.Dir)
+	assert.Equal(t, conf.Ledger, conf.ForExtension(conf.Extensions[0]).Ledger)
+	assert.Equal(t, conf.Artifacts, conf.ForExtension(conf.Extensions[0]).Artifacts)
+
+	t.Setenv("CWS_LEDGER", "/var/lib/cws/ledger.jsonl")
+	t.Setenv("CWS_ARTIFACTS_DIR", "/var/cache/cws")
+	conf, err = LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, "/var/lib/cws/ledger.jsonl", conf.Ledger)
+	assert.Equal(t, "/var/cache/cws", conf.Artifacts.Dir)
+	assert.Equal(t, "env CWS_ARTIFACTS_DIR", conf.Sources["artifacts.dir"])
+}
+
+func TestLoadConfigLint(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ConfigFileName), `{"client_id": "client", "client_secret": "secret", "refresh_token": "token",
+		"lint": {"suppress": ["eval:vendor/*"]}, "extensions": [{"name": "main", "extension_id": "main-ext"}]}`)
+
+	conf, err := LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"eval:vendor/*"}, conf.Lint.Suppress)
+	assert.Equal(t, conf.Lint, conf.ForExtension(conf.Extensions[0]).Lint)
+
+	t.Setenv("CWS_LINT_SUPPRESS", "eval,remote-code")
+	conf, err = LoadConfig(LoadOptions{Dir: dir})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"eval", "remote-code"}, conf.Lint.Suppress)
+	assert.Equal(t, "env CWS_LINT_SUPPRESS", conf.Sources["lint.suppress"])
+}
+
+func TestArtifactsRetention(t *testing.T) {
+	policy, err := Artifacts{}.Retention()
+	require.Nil(t, err)
+	assert.Equal(t, artifacts.Retention{}, policy)
+
+	policy, err = Artifacts{Keep: 5, MaxAge: "720h"}.Retention()
+	require.Nil(t, err)
+	assert.Equal(t, artifacts.Retention{Keep: 5, MaxAge: 720 * time.Hour}, policy)
+
+	_, err = Artifacts{MaxAge: "30d"}.Retention()
+	assert.ErrorContains(t, err, "invalid artifacts.max_age")
+	_, err = Artifacts{Keep: -1}.Retention()
+	assert.ErrorContains(t, err, "invalid artifacts.keep")
+}
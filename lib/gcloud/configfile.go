@@ -0,0 +1,281 @@
+package gcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigFile edits a single config file. Values are checked against the Config
+// struct so that only known keys with valid values are written, while any other
+// values already in the file are kept as they are.
+type ConfigFile struct {
+	Path   string
+	values map[string]json.RawMessage
+}
+
+// OpenConfigFile reads the config file at path for editing, a missing file is
+// treated as an empty config
+func OpenConfigFile(path string) (*ConfigFile, error) {
+	file := &ConfigFile{Path: path, values: map[string]json.RawMessage{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(data, &file.values); err != nil {
+		return nil, fmt.Errorf("parsing config %v: %w", path, err)
+	}
+	return file, nil
+}
+
+// Set parses the value for the key and sets it. Keys are the json names in the
+// config, profiles.<profile>.<key> or extensions.<name>.<key>
+func (file *ConfigFile) Set(key, value string) error {
+	return file.edit(key, &value)
+}
+
+// Unset removes the key from the config. Whole profiles and extensions can be
+// removed with profiles.<profile> and extensions.<name>
+func (file *ConfigFile) Unset(key string) error {
+	return file.edit(key, nil)
+}
+
+// Save writes the config file, readable only by the current user since it
+// contains credentials
+func (file *ConfigFile) Save() error {
+	data, err := json.MarshalIndent(file.values, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0700); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(file.Path, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	// WriteFile only sets the permissions of new files
+	return os.Chmod(file.Path, 0600)
+}
+
+func (file *ConfigFile) edit(key string, value *string) error {
+	values := copyValues(file.values)
+	if err := editObject(values, reflect.TypeOf(Config{}), key, strings.Split(key, "."), value); err != nil {
+		return err
+	}
+	// make sure the whole config still parses before keeping the change
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	} else if err := json.Unmarshal(data, &Config{}); err != nil {
+		return fmt.Errorf("invalid value for %v: %w", key, err)
+	}
+	file.values = values
+	return nil
+}
+
+// namedSection is a profile or an extension in the config
+type namedSection struct {
+	name   string
+	values map[string]json.RawMessage
+}
+
+func editObject(obj map[string]json.RawMessage, typ reflect.Type, key string, parts []string, value *string) error {
+	field, ok := fieldByJSONKey(typ, parts[0])
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	} else if kind := field.Type.Kind(); kind == reflect.Map || (kind == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct) {
+		return editNamed(obj, field, key, parts, value)
+	} else if field.Type.Kind() == reflect.Struct && len(parts) > 1 {
+		return editNested(obj, field, key, parts, value)
+	} else if len(parts) != 1 {
+		return fmt.Errorf("unknown config key %q", key)
+	} else if value == nil {
+		delete(obj, parts[0])
+		return nil
+	}
+	var parsed interface{} = *value
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(*value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %v, expected true or false", key)
+		}
+		parsed = b
+	case reflect.Int:
+		n, err := strconv.Atoi(*value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %v, expected a number", key)
+		}
+		parsed = n
+	case reflect.Slice:
+		// lists of strings are set as a comma separated value
+		parsed = strings.Split(*value, ",")
+	}
+	raw, err := json.Marshal(parsed)
+	if err != nil {
+		return err
+	}
+	obj[parts[0]] = raw
+	return nil
+}
+
+// editNested edits a value in an object like hooks, keyed by hooks.<key>
+func editNested(obj map[string]json.RawMessage, field reflect.StructField, key string, parts []string, value *string) error {
+	nested := map[string]json.RawMessage{}
+	if raw, ok := obj[parts[0]]; ok {
+		if err := json.Unmarshal(raw, &nested); err != nil {
+			return fmt.Errorf("reading %v: %w", parts[0], err)
+		}
+	}
+	if err := editObject(nested, field.Type, key, parts[1:], value); err != nil {
+		return err
+	} else if len(nested) == 0 {
+		delete(obj, parts[0])
+		return nil
+	}
+	raw, err := json.Marshal(nested)
+	obj[parts[0]] = raw
+	return err
+}
+
+// editNamed edits a value in a profile or an extension, keyed by
+// profiles.<name>.<key> and extensions.<name>.<key>. The whole section is
+// removed when unsetting profiles.<name> or extensions.<name>
+func editNamed(obj map[string]json.RawMessage, field reflect.StructField, key string, parts []string, value *string) error {
+	isList := field.Type.Kind() == reflect.Slice
+	if len(parts) < 2 || (len(parts) == 2 && value != nil) {
+		return fmt.Errorf("%v needs a name and a key like %v.<name>.<key>", parts[0], parts[0])
+	}
+	sections, err := loadNamed(obj[parts[0]], isList)
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", parts[0], err)
+	}
+	index := -1
+	for i, section := range sections {
+		if section.name == parts[1] {
+			index = i
+		}
+	}
+
+	if len(parts) == 2 && index >= 0 {
+		sections = append(sections[:index], sections[index+1:]...)
+	} else if len(parts) > 2 && (index >= 0 || value != nil) {
+		if index < 0 {
+			section := namedSection{name: parts[1], values: map[string]json.RawMessage{}}
+			if isList {
+				section.values["name"], _ = json.Marshal(parts[1])
+			}
+			sections = append(sections, section)
+			index = len(sections) - 1
+		}
+		if err := editObject(sections[index].values, field.Type.Elem(), key, parts[2:], value); err != nil {
+			return err
+		}
+		if len(sections[index].values) == 0 {
+			sections = append(sections[:index], sections[index+1:]...)
+		}
+	}
+
+	if len(sections) == 0 {
+		delete(obj, parts[0])
+		return nil
+	}
+	var collection interface{}
+	if isList {
+		list := []map[string]json.RawMessage{}
+		for _, section := range sections {
+			list = append(list, section.values)
+		}
+		collection = list
+	} else {
+		named := map[string]map[string]json.RawMessage{}
+		for _, section := range sections {
+			named[section.name] = section.values
+		}
+		collection = named
+	}
+	obj[parts[0]], err = json.Marshal(collection)
+	return err
+}
+
+func loadNamed(raw json.RawMessage, isList bool) ([]namedSection, error) {
+	sections := []namedSection{}
+	if raw == nil {
+		return sections, nil
+	} else if isList {
+		list := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		for _, values := range list {
+			var name string
+			json.Unmarshal(values["name"], &name)
+			sections = append(sections, namedSection{name: name, values: values})
+		}
+		return sections, nil
+	}
+	named := map[string]map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &named); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := named[name]
+		if values == nil {
+			values = map[string]json.RawMessage{}
+		}
+		sections = append(sections, namedSection{name: name, values: values})
+	}
+	return sections, nil
+}
+
+// IsSecretKey reports whether a config key, like client_secret or
+// extensions.<name>.refresh_token, is a value that is tagged as secret
+func IsSecretKey(key string) bool {
+	typ, parts := reflect.TypeOf(Config{}), strings.Split(key, ".")
+	for len(parts) > 0 {
+		field, ok := fieldByJSONKey(typ, parts[0])
+		if !ok {
+			return false
+		}
+		switch kind := field.Type.Kind(); {
+		case kind == reflect.Map || (kind == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct):
+			if len(parts) < 3 {
+				return false
+			}
+			typ, parts = field.Type.Elem(), parts[2:]
+		case kind == reflect.Struct && len(parts) > 1:
+			typ, parts = field.Type, parts[1:]
+		default:
+			return len(parts) == 1 && field.Tag.Get("secret") == "true"
+		}
+	}
+	return false
+}
+
+func fieldByJSONKey(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); jsonKey(field) == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func copyValues(values map[string]json.RawMessage) map[string]json.RawMessage {
+	copied := map[string]json.RawMessage{}
+	for key, val := range values {
+		copied[key] = val
+	}
+	return copied
+}
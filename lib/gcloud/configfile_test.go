@@ -0,0 +1,123 @@
+package gcloud
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFileSetUnset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	writeFile(t, path, `{"extension_id": "old", "custom": {"keep": [1, 2]}}`)
+	require.Nil(t, os.Chmod(path, 0666))
+
+	file, err := OpenConfigFile(path)
+	require.Nil(t, err)
+	assert.Nil(t, file.Set("extension_id", "abc"))
+	assert.Nil(t, file.Set("debug", "true"))
+	assert.Nil(t, file.Set("client_id", "id"))
+	assert.Nil(t, file.Unset("client_id"))
+	assert.Nil(t, file.Set("ca_files", "proxy-ca.pem,corp-ca.pem"))
+	assert.EqualError(t, file.Set("debug", "maybe"), "invalid value for debug, expected true or false")
+	assert.EqualError(t, file.Set("secret_id", "abc"), `unknown config key "secret_id"`)
+	assert.EqualError(t, file.Set("extension_id.nested", "abc"), `unknown config key "extension_id.nested"`)
+	require.Nil(t, file.Save())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"extension_id": "abc", "debug": true, "ca_files": ["proxy-ca.pem", "corp-ca.pem"], "custom": {"keep": [1, 2]}}`, string(data))
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestConfigFileNamedSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", ConfigFileName)
+	file, err := OpenConfigFile(path)
+	require.Nil(t, err)
+
+	assert.Nil(t, file.Set("profiles.prod.extension_id", "prod-ext"))
+	assert.Nil(t, file.Set("profiles.prod.client_secret", "secret"))
+	assert.Nil(t, file.Unset("profiles.prod.client_secret"))
+	assert.Nil(t, file.Set("profiles.dev.extension_id", "dev-ext"))
+	assert.Nil(t, file.Unset("profiles.dev"))
+	assert.Nil(t, file.Set("extensions.beta.extension_id", "beta-ext"))
+	assert.Nil(t, file.Set("extensions.beta.publish_target", "trustedTesters"))
+	assert.Nil(t, file.Set("extensions.main.extension_id", "main-ext"))
+	assert.Nil(t, file.Unset("extensions.main"))
+	assert.EqualError(t, file.Set("profiles.prod", "abc"), "profiles needs a name and a key like profiles.<name>.<key>")
+	assert.EqualError(t, file.Set("extensions.beta.bogus", "abc"), `unknown config key "extensions.beta.bogus"`)
+	require.Nil(t, file.Save())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{
+		"profiles": {"prod": {"extension_id": "prod-ext"}},
+		"extensions": [{"name": "beta", "extension_id": "beta-ext", "publish_target": "trustedTesters"}]
+	}`, string(data))
+}
+
+func TestConfigFileNested(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	file, err := OpenConfigFile(path)
+	require.Nil(t, err)
+	assert.Nil(t, file.Set("hooks.prebuild", "npm run build"))
+	assert.Nil(t, file.Set("hooks.timeout", "5m"))
+	assert.Nil(t, file.Set("artifacts.keep", "10"))
+	assert.EqualError(t, file.Set("artifacts.keep", "ten"), "invalid value for artifacts.keep, expected a number")
+	assert.Nil(t, file.Set("extensions.beta.hooks.postpublish", "git tag beta"))
+	assert.EqualError(t, file.Set("hooks.deploy", "x"), `unknown config key "hooks.deploy"`)
+	assert.Nil(t, file.Unset("hooks.timeout"))
+	require.Nil(t, file.Save())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{
+		"hooks": {"prebuild": "npm run build"},
+		"artifacts": {"keep": 10},
+		"extensions": [{"name": "beta", "hooks": {"postpublish": "git tag beta"}}]
+	}`, string(data))
+
+	assert.Nil(t, file.Unset("hooks.prebuild"))
+	assert.Nil(t, file.Unset("artifacts.keep"))
+	require.Nil(t, file.Save())
+	data, err = os.ReadFile(path)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"extensions": [{"name": "beta", "hooks": {"postpublish": "git tag beta"}}]}`, string(data))
+}
+
+func TestConfigFileNotifiers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	file, err := OpenConfigFile(path)
+	require.Nil(t, err)
+	assert.Nil(t, file.Set("notifiers.team.type", "slack"))
+	assert.Nil(t, file.Set("notifiers.team.url", "env:SLACK_WEBHOOK"))
+	assert.Nil(t, file.Set("notifiers.team.events", "publish,rejected"))
+	require.Nil(t, file.Save())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"notifiers": [{"name": "team", "type": "slack", "url": "env:SLACK_WEBHOOK", "events": ["publish", "rejected"]}]}`, string(data))
+}
+
+func TestIsSecretKey(t *testing.T) {
+	for key, secret := range map[string]bool{
+		"client_secret":                   true,
+		"refresh_token":                   true,
+		"profiles.ci.client_secret":       true,
+		"extensions.main.refresh_token":   true,
+		"notifiers.team.url":              true,
+		"client_id":                       false,
+		"artifacts.keep":                  false,
+		"extensions.main.hooks.prebuild":  false,
+		"extensions.main":                 false,
+		"profiles.ci.artifacts.keep":      false,
+		"unknown":                         false,
+		"extensions.main.client_secret.x": false,
+	} {
+		assert.Equal(t, secret, IsSecretKey(key), key)
+	}
+}
@@ -0,0 +1,92 @@
+package gcloud
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// AuthError is returned when the credentials could not be exchanged for an
+	// access token. Err is the underlying cause, usually an *APIError
+	AuthError struct {
+		Err error
+	}
+	// APIError is returned when the api responds with an error
+	APIError struct {
+		Code    int
+		Status  string
+		Message string
+		Reasons []string
+	}
+	// UploadError is returned when the webstore did not accept an uploaded archive
+	UploadError struct {
+		State  string
+		Errors []WebStoreItemError
+	}
+	// PublishError is returned when the webstore rejected publishing the item
+	PublishError struct {
+		Status []string
+		Detail []string
+	}
+)
+
+func (err *AuthError) Error() string {
+	if err.Err == nil {
+		return "authentication failed"
+	}
+	return fmt.Sprintf("authentication failed: %v", err.Err)
+}
+
+func (err *AuthError) Unwrap() error {
+	return err.Err
+}
+
+// Is matches any other *AuthError so that errors.Is(err, &AuthError{}) works
+func (err *AuthError) Is(target error) bool {
+	_, ok := target.(*AuthError)
+	return ok
+}
+
+func (err *APIError) Error() string {
+	msg := fmt.Sprintf("(%v)%v %v", err.Code, err.Status, err.Message)
+	if len(err.Reasons) > 0 {
+		msg += " [" + strings.Join(err.Reasons, ", ") + "]"
+	}
+	return msg
+}
+
+// Is matches another *APIError, only comparing the code and status when they
+// are set on the target, so errors.Is(err, &APIError{Code: 404}) works
+func (err *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok &&
+		(t.Code == 0 || t.Code == err.Code) &&
+		(t.Status == "" || t.Status == err.Status)
+}
+
+func (err *UploadError) Error() string {
+	details := []string{}
+	for _, itemErr := range err.Errors {
+		details = append(details, fmt.Sprintf("%v: %v", itemErr.Code, itemErr.Detail))
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("upload failed with state %v", err.State)
+	}
+	return fmt.Sprintf("upload failed with state %v: %v", err.State, strings.Join(details, "; "))
+}
+
+// Is matches another *UploadError, only comparing the state if it is set on the target
+func (err *UploadError) Is(target error) bool {
+	t, ok := target.(*UploadError)
+	return ok && (t.State == "" || t.State == err.State)
+}
+
+func (err *PublishError) Error() string {
+	return fmt.Sprintf("failed to publish extension with status: %v errors: %v", strings.Join(err.Status, ", "), strings.Join(err.Detail, ", "))
+}
+
+// Is matches any other *PublishError
+func (err *PublishError) Is(target error) bool {
+	_, ok := target.(*PublishError)
+	return ok
+}
@@ -0,0 +1,47 @@
+package gcloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAPIError(t *testing.T) {
+	err := parseAPIError(http.StatusOK, []byte(`{"crxVersion": "1.0.0"}`))
+	assert.Nil(t, err)
+
+	err = parseAPIError(http.StatusForbidden, []byte(`{"error": {"code": 403, "message": "not allowed", "status": "PERMISSION_DENIED", "errors": [{"message": "not allowed", "reason": "forbidden"}]}}`))
+	assert.Equal(t, &APIError{Code: 403, Status: "PERMISSION_DENIED", Message: "not allowed", Reasons: []string{"forbidden"}}, err)
+	assert.Equal(t, "(403)PERMISSION_DENIED not allowed [forbidden]", err.Error())
+
+	err = parseAPIError(http.StatusBadRequest, []byte(`{"error": "invalid_grant", "error_description": "Bad Request"}`))
+	assert.Equal(t, &APIError{Code: 400, Status: "invalid_grant", Message: "Bad Request"}, err)
+
+	err = parseAPIError(http.StatusBadGateway, []byte(`<html>bad gateway</html>`))
+	assert.Equal(t, &APIError{Code: 502, Status: "Bad Gateway"}, err)
+}
+
+func TestErrorsIsAs(t *testing.T) {
+	apiErr := &APIError{Code: 401, Status: "UNAUTHENTICATED"}
+	err := fmt.Errorf("wrapped: %w", &AuthError{Err: apiErr})
+	assert.True(t, errors.Is(err, &AuthError{}))
+	assert.True(t, errors.Is(err, &APIError{Code: 401}))
+	assert.False(t, errors.Is(err, &APIError{Code: 404}))
+	assert.False(t, errors.Is(err, &UploadError{}))
+
+	var target *APIError
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, apiErr, target)
+
+	uploadErr := &UploadError{State: "FAILURE", Errors: []WebStoreItemError{{Code: "ITEM_ERR", Detail: "bad zip"}}}
+	assert.True(t, errors.Is(uploadErr, &UploadError{State: "FAILURE"}))
+	assert.False(t, errors.Is(uploadErr, &UploadError{State: "IN_PROGRESS"}))
+	assert.Equal(t, "upload failed with state FAILURE: ITEM_ERR: bad zip", uploadErr.Error())
+
+	publishErr := &PublishError{Status: []string{"ITEM_PENDING_REVIEW"}, Detail: []string{"in review"}}
+	assert.True(t, errors.Is(publishErr, &PublishError{}))
+	assert.Equal(t, "failed to publish extension with status: ITEM_PENDING_REVIEW errors: in review", publishErr.Error())
+}
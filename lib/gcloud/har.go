@@ -0,0 +1,151 @@
+package gcloud
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// HAR collects the exchanges of every client in the HTTP Archive format so
+	// that a run can be inspected in a browser's network tools. Secrets are
+	// redacted before they are recorded.
+	HAR struct {
+		mu      sync.Mutex
+		entries []harEntry
+	}
+	harEntry struct {
+		StartedDateTime time.Time   `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+	}
+	harRequest struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Headers     []harNVP    `json:"headers"`
+		QueryString []harNVP    `json:"queryString"`
+		PostData    *harContent `json:"postData,omitempty"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int64       `json:"bodySize"`
+	}
+	harResponse struct {
+		Status      int        `json:"status"`
+		StatusText  string     `json:"statusText"`
+		HTTPVersion string     `json:"httpVersion"`
+		Headers     []harNVP   `json:"headers"`
+		Content     harContent `json:"content"`
+		RedirectURL string     `json:"redirectURL"`
+		HeadersSize int        `json:"headersSize"`
+		BodySize    int64      `json:"bodySize"`
+	}
+	harContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+	}
+	harNVP struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+var har *HAR
+
+// SetHAR makes every client record its exchanges into h, nil stops recording
+func SetHAR(h *HAR) {
+	har = h
+}
+
+// WriteFile saves the recorded exchanges as a .har file
+func (h *HAR) WriteFile(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	doc := map[string]interface{}{
+		"log": map[string]interface{}{
+			"version": "1.2",
+			"creator": map[string]string{"name": "cws", "version": "0.0.1"},
+			"entries": append([]harEntry{}, h.entries...),
+		},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// record adds an exchange, reqBody is empty when the body was not captured
+// like for archive uploads
+func (h *HAR) record(client *Client, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, started time.Time, latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+	entry := harEntry{
+		StartedDateTime: started,
+		Time:            ms,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         client.redact(req.URL.String()),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(client, req.Header),
+			QueryString: []harNVP{},
+			HeadersSize: -1,
+			BodySize:    req.ContentLength,
+		},
+		Timings: harTimings{Wait: ms},
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNVP{Name: name, Value: client.redact(value)})
+		}
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &harContent{
+			Size:     int64(len(reqBody)),
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     client.redact(string(reqBody)),
+		}
+	}
+	if resp != nil {
+		entry.Response = harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Headers:     harHeaders(client, resp.Header),
+			Content: harContent{
+				Size:     int64(len(respBody)),
+				MimeType: resp.Header.Get("Content-Type"),
+				Text:     client.redact(string(respBody)),
+			},
+			HeadersSize: -1,
+			BodySize:    int64(len(respBody)),
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+}
+
+func harHeaders(client *Client, header http.Header) []harNVP {
+	headers := []harNVP{}
+	for name, values := range header {
+		for _, value := range values {
+			if name == "Authorization" || name == "Cookie" || name == "Set-Cookie" {
+				value = "[REDACTED]"
+			}
+			headers = append(headers, harNVP{Name: name, Value: client.redact(value)})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
@@ -0,0 +1,95 @@
+package gcloud
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// transport replaces the transport built from the config, like a Cassette
+var transport http.RoundTripper
+
+// SetTransport makes new clients send requests with rt, nil restores the
+// transport built from the config
+func SetTransport(rt http.RoundTripper) {
+	transport = rt
+}
+
+// HTTPClient is the client that every request made with the config is sent
+// with, including the oauth token exchange. It is built once, with the proxy,
+// CA and client certificate settings, and shared with the extension configs.
+func (conf *Config) HTTPClient() (*http.Client, error) {
+	if conf.httpClient != nil {
+		return conf.httpClient, nil
+	} else if transport != nil {
+		conf.httpClient = &http.Client{Timeout: requestTimeout, Transport: transport}
+		return conf.httpClient, nil
+	}
+	tlsConfig, err := conf.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := conf.proxy()
+	if err != nil {
+		return nil, err
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = tlsConfig
+	base.Proxy = proxy
+	conf.httpClient = &http.Client{Timeout: requestTimeout, Transport: base}
+	return conf.httpClient, nil
+}
+
+func (conf *Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(conf.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range conf.CAFiles {
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading ca_files: %w", err)
+			} else if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates were found in the ca file %v", path)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.ClientCert != "" || conf.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(conf.ClientCert, conf.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client_cert and client_key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// proxy uses HTTPS_PROXY and NO_PROXY from the environment, unless they are
+// overridden in the config
+func (conf *Config) proxy() (func(*http.Request) (*url.URL, error), error) {
+	if conf.HTTPSProxy == "" && conf.NoProxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyConf := httpproxy.FromEnvironment()
+	if conf.HTTPSProxy != "" {
+		if _, err := url.Parse(conf.HTTPSProxy); err != nil {
+			return nil, fmt.Errorf("invalid https_proxy: %w", err)
+		}
+		proxyConf.HTTPSProxy = conf.HTTPSProxy
+	}
+	if conf.NoProxy != "" {
+		proxyConf.NoProxy = conf.NoProxy
+	}
+	proxyFunc := proxyConf.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
@@ -0,0 +1,178 @@
+package gcloud
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
+
+// connectProxy stands in for an egress proxy, every CONNECT is tunnelled to
+// target no matter the host that was asked for
+func connectProxy(t *testing.T, target string) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	hosts := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.Nil(t, err)
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() { io.Copy(upstream, conn); upstream.Close() }()
+		go func() { io.Copy(conn, upstream); conn.Close() }()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, &hosts
+}
+
+// writeClientCert creates a self signed client certificate and returns the
+// paths of the certificate and key and a pool that trusts it
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cws-runner"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.Nil(t, err)
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	writeFile(t, certPath, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})))
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certPath, keyPath, pool
+}
+
+func TestHTTPClientProxyCAAndMTLS(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCAs := writeClientCert(t, dir)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caPath := filepath.Join(dir, "ca.pem")
+	writeFile(t, caPath, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+
+	proxy, hosts := connectProxy(t, server.Listener.Addr().String())
+	// the test certificate is valid for example.com, the proxy resolves it to
+	// the test server
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	target := "https://example.com:" + port + "/items"
+
+	conf := &Config{HTTPSProxy: proxy.URL, CAFiles: []string{caPath}, ClientCert: certPath, ClientKey: keyPath}
+	client, err := conf.HTTPClient()
+	require.Nil(t, err)
+	resp, err := client.Get(target)
+	require.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "hello cws-runner", string(body))
+	assert.Equal(t, []string{"example.com:" + port}, *hosts)
+
+	again, err := conf.HTTPClient()
+	require.Nil(t, err)
+	assert.Same(t, client, again)
+	extClient, err := conf.ForExtension(Extension{}).HTTPClient()
+	require.Nil(t, err)
+	assert.Same(t, client, extClient)
+
+	untrusted, err := (&Config{HTTPSProxy: proxy.URL, ClientCert: certPath, ClientKey: keyPath}).HTTPClient()
+	require.Nil(t, err)
+	_, err = untrusted.Get(target)
+	assert.ErrorContains(t, err, "certificate")
+
+	noCert, err := (&Config{HTTPSProxy: proxy.URL, CAFiles: []string{caPath}}).HTTPClient()
+	require.Nil(t, err)
+	_, err = noCert.Get(target)
+	assert.NotNil(t, err)
+}
+
+func TestHTTPClientNoProxy(t *testing.T) {
+	conf := &Config{HTTPSProxy: "http://proxy.internal:3128", NoProxy: "internal.example.com"}
+	proxy, err := conf.proxy()
+	require.Nil(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.googleapis.com/chromewebstore", nil)
+	proxyURL, err := proxy(req)
+	require.Nil(t, err)
+	assert.Equal(t, &url.URL{Scheme: "http", Host: "proxy.internal:3128"}, proxyURL)
+
+	req, _ = http.NewRequest(http.MethodGet, "https://internal.example.com", nil)
+	proxyURL, err = proxy(req)
+	require.Nil(t, err)
+	assert.Nil(t, proxyURL)
+}
+
+func TestHTTPClientInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	writeFile(t, notPEM, "not a certificate")
+
+	_, err := (&Config{CAFiles: []string{filepath.Join(dir, "missing.pem")}}).HTTPClient()
+	assert.ErrorContains(t, err, "ca_files")
+	_, err = (&Config{CAFiles: []string{notPEM}}).HTTPClient()
+	assert.ErrorContains(t, err, "no certificates")
+	_, err = (&Config{ClientCert: notPEM}).HTTPClient()
+	assert.ErrorContains(t, err, "client_cert")
+}
+
+func TestAuthenticatorUsesConfigClient(t *testing.T) {
+	var sent *http.Request
+	conf := &Config{ID: "client", Secret: "secret"}
+	conf.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"refresh_token": "refresh"}`)),
+		}, nil
+	})}
+	access, err := NewAuthenticator(conf, "scope").exchangeCode("code")
+	require.Nil(t, err)
+	assert.Equal(t, "refresh", access.RefreshToken)
+	assert.Equal(t, "oauth2.googleapis.com", sent.URL.Host)
+}
@@ -0,0 +1,112 @@
+// Package hooks runs the commands configured to run around the steps of a
+// release, like building the extension before it is archived.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// Hooks in the order they run. OnFailure runs instead of the remaining hooks
+// when a step fails.
+const (
+	Prebuild    = "prebuild"
+	Postarchive = "postarchive"
+	Preupload   = "preupload"
+	Postupload  = "postupload"
+	Postpublish = "postpublish"
+	OnFailure   = "onfailure"
+)
+
+// DefaultTimeout is how long a hook can run when no timeout is configured
+const DefaultTimeout = 10 * time.Minute
+
+type (
+	// Runner runs hook commands with the shell
+	Runner struct {
+		// Commands are keyed by hook name, hooks without a command are skipped
+		Commands map[string]string
+		// Timeout limits each hook, defaults to DefaultTimeout
+		Timeout time.Duration
+		// Dir is where hooks run, defaults to the working directory
+		Dir    string
+		Stdout io.Writer
+		Stderr io.Writer
+	}
+	// Error is returned when a hook exits with an error or times out
+	Error struct {
+		Hook     string
+		Command  string
+		Err      error
+		TimedOut bool
+		Timeout  time.Duration
+	}
+)
+
+func (err *Error) Error() string {
+	if err.TimedOut {
+		return fmt.Sprintf("%v hook timed out after %v: %v", err.Hook, err.Timeout, err.Command)
+	}
+	return fmt.Sprintf("%v hook failed: %v: %v", err.Hook, err.Command, err.Err)
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Has reports whether a command is configured for the hook
+func (runner Runner) Has(hook string) bool {
+	return runner.Commands[hook] != ""
+}
+
+// Run runs the command of the hook with env added to the environment. The
+// hook and everything it started is killed when it times out.
+func (runner Runner) Run(hook string, env map[string]string) error {
+	command := runner.Commands[hook]
+	if command == "" {
+		return nil
+	}
+	timeout := runner.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := shell(ctx, command)
+	cmd.Dir = runner.Dir
+	cmd.Stdout, cmd.Stderr = runner.Stdout, runner.Stderr
+	cmd.Env = append(os.Environ(), "CWS_HOOK="+hook)
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+	// output pipes held open by orphaned processes should not block forever
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &Error{Hook: hook, Command: command, Err: ctx.Err(), TimedOut: true, Timeout: timeout}
+	} else if err != nil {
+		return &Error{Hook: hook, Command: command, Err: err}
+	}
+	return nil
+}
+
+// shell runs the command with sh in its own process group so that a timeout
+// kills what it started as well
+func shell(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	setProcessGroup(cmd)
+	return cmd
+}
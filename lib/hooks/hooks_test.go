@@ -0,0 +1,56 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	runner := Runner{
+		Commands: map[string]string{
+			Postarchive: `echo "$CWS_HOOK $CWS_VERSION $CWS_ARCHIVE_SHA256"`,
+			Preupload:   "exit 3",
+		},
+		Dir:    t.TempDir(),
+		Stdout: &out,
+	}
+
+	assert.True(t, runner.Has(Postarchive))
+	assert.False(t, runner.Has(Prebuild))
+	require.Nil(t, runner.Run(Prebuild, nil))
+	require.Nil(t, runner.Run(Postarchive, map[string]string{"CWS_VERSION": "1.2.3", "CWS_ARCHIVE_SHA256": "abc"}))
+	assert.Equal(t, "postarchive 1.2.3 abc\n", out.String())
+
+	err := runner.Run(Preupload, nil)
+	hookErr := &Error{}
+	require.ErrorAs(t, err, &hookErr)
+	assert.Equal(t, Preupload, hookErr.Hook)
+	assert.False(t, hookErr.TimedOut)
+	exitErr := &exec.ExitError{}
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.EqualError(t, err, "preupload hook failed: exit 3: exit status 3")
+}
+
+func TestRunTimeout(t *testing.T) {
+	runner := Runner{
+		Commands: map[string]string{Prebuild: "sleep 5 & sleep 5"},
+		Timeout:  100 * time.Millisecond,
+	}
+	start := time.Now()
+	err := runner.Run(Prebuild, nil)
+	assert.Less(t, time.Since(start), 3*time.Second)
+	hookErr := &Error{}
+	require.ErrorAs(t, err, &hookErr)
+	assert.True(t, hookErr.TimedOut)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.EqualError(t, err, "prebuild hook timed out after 100ms: sleep 5 & sleep 5")
+}
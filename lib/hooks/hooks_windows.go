@@ -0,0 +1,9 @@
+//go:build windows
+
+package hooks
+
+import "os/exec"
+
+// setProcessGroup is a no-op on windows, there are no process groups to kill
+// so a timeout only kills the shell and not what it started
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,150 @@
+// Package ledger keeps an append-only record of every upload, publish and
+// rollout so that a version can be traced back to the archive and commit it
+// was built from. Entries are stored as json lines.
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions that are recorded
+const (
+	ActionUpload  = "upload"
+	ActionPublish = "publish"
+	ActionRollout = "rollout"
+)
+
+// Results of an action
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+type (
+	// Entry is a single recorded action
+	Entry struct {
+		Time   time.Time `json:"time"`
+		Action string    `json:"action"`
+		// Name is the name of the extension in a config with several extensions
+		Name          string `json:"name,omitempty"`
+		ExtensionID   string `json:"extension_id"`
+		Version       string `json:"version,omitempty"`
+		Commit        string `json:"commit,omitempty"`
+		ArchiveSHA256 string `json:"archive_sha256,omitempty"`
+		PublishTarget string `json:"publish_target,omitempty"`
+		// Actor is who ran the release, the git user or the login name
+		Actor            string `json:"actor,omitempty"`
+		Result           string `json:"result"`
+		State            string `json:"state,omitempty"`
+		DeployPercentage int    `json:"deploy_percentage,omitempty"`
+		Error            string `json:"error,omitempty"`
+	}
+	// Filter selects entries, empty fields match everything
+	Filter struct {
+		// Extension matches the name or the id of the extension
+		Extension string
+		Version   string
+		Action    string
+		Result    string
+		Since     time.Time
+		// Limit keeps only the most recent entries
+		Limit int
+	}
+)
+
+// appendMu keeps the lines of concurrent releases in one process from
+// interleaving
+var appendMu sync.Mutex
+
+// DefaultPath is the ledger in $XDG_STATE_HOME/cws
+func DefaultPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "cws", "ledger.jsonl")
+}
+
+// Append adds an entry to the end of the ledger at path, creating it if needed
+func Append(path string, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	appendMu.Lock()
+	defer appendMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Read loads every entry of the ledger at path, oldest first. A ledger that
+// does not exist yet has no entries.
+func Read(path string) ([]Entry, error) {
+	entries := []Entry{}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("reading ledger %v line %v: %w", path, lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Apply returns the entries that match the filter, oldest first
+func (filter Filter) Apply(entries []Entry) []Entry {
+	matched := []Entry{}
+	for _, entry := range entries {
+		if filter.Match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched
+}
+
+// Match is true when the entry passes the filter
+func (filter Filter) Match(entry Entry) bool {
+	return (filter.Extension == "" || filter.Extension == entry.Name || filter.Extension == entry.ExtensionID) &&
+		(filter.Version == "" || filter.Version == entry.Version) &&
+		(filter.Action == "" || filter.Action == entry.Action) &&
+		(filter.Result == "" || filter.Result == entry.Result) &&
+		(filter.Since.IsZero() || !entry.Time.Before(filter.Since))
+}
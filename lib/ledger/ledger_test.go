@@ -0,0 +1,75 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "ledger.jsonl")
+	entries, err := Read(path)
+	require.Nil(t, err)
+	assert.Empty(t, entries)
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	upload := Entry{Time: start, Action: ActionUpload, ExtensionID: "abc", Version: "1.0", Commit: "deadbeef", ArchiveSHA256: "0123", Result: ResultOK, State: "SUCCESS"}
+	publish := Entry{Time: start.Add(time.Minute), Action: ActionPublish, ExtensionID: "abc", Version: "1.0", PublishTarget: "default", Result: ResultFailed, Error: "rejected"}
+	require.Nil(t, Append(path, upload))
+	require.Nil(t, Append(path, publish))
+
+	entries, err = Read(path)
+	require.Nil(t, err)
+	assert.Equal(t, []Entry{upload, publish}, entries)
+
+	require.Nil(t, Append(path, Entry{Action: ActionRollout, ExtensionID: "abc", Result: ResultOK}))
+	entries, err = Read(path)
+	require.Nil(t, err)
+	require.Len(t, entries, 3)
+	assert.False(t, entries[2].Time.IsZero(), "the time defaults to now")
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, Append(path, Entry{Action: ActionUpload, ExtensionID: "abc", Result: ResultOK}))
+		}()
+	}
+	wg.Wait()
+	entries, err := Read(path)
+	require.Nil(t, err)
+	assert.Len(t, entries, 20)
+}
+
+func TestReadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	require.Nil(t, os.WriteFile(path, []byte("{\"action\": \"upload\"}\n\nnot json\n"), 0644))
+	_, err := Read(path)
+	assert.ErrorContains(t, err, "line 3")
+}
+
+func TestFilter(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, Action: ActionUpload, Name: "main", ExtensionID: "abc", Version: "1.0", Result: ResultOK},
+		{Time: start.Add(time.Hour), Action: ActionPublish, Name: "main", ExtensionID: "abc", Version: "1.0", Result: ResultFailed},
+		{Time: start.Add(2 * time.Hour), Action: ActionUpload, Name: "beta", ExtensionID: "def", Version: "2.0", Result: ResultOK},
+	}
+	assert.Equal(t, entries, Filter{}.Apply(entries))
+	assert.Equal(t, entries[:2], Filter{Extension: "main"}.Apply(entries))
+	assert.Equal(t, entries[2:], Filter{Extension: "def"}.Apply(entries))
+	assert.Equal(t, entries[1:2], Filter{Result: ResultFailed}.Apply(entries))
+	assert.Equal(t, []Entry{entries[0], entries[2]}, Filter{Action: ActionUpload}.Apply(entries))
+	assert.Equal(t, entries[:2], Filter{Version: "1.0"}.Apply(entries))
+	assert.Equal(t, entries[1:], Filter{Since: start.Add(time.Hour)}.Apply(entries))
+	assert.Equal(t, entries[2:], Filter{Limit: 1}.Apply(entries))
+}
@@ -0,0 +1,131 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type (
+	// contentSecurity is the manifest v3 policy of each kind of page, a manifest
+	// v2 policy is a single string that applies to the extension pages
+	contentSecurity struct {
+		ExtensionPages string `json:"extension_pages"`
+		Sandbox        string `json:"sandbox"`
+	}
+	// codePattern is an obvious way for a script to run code that is not in the
+	// package
+	codePattern struct {
+		rule     string
+		severity string
+		expr     *regexp.Regexp
+		msg      string
+	}
+)
+
+// codePatterns are what checkCode looks for. eval and new Function are only
+// warnings as bundled libraries often have them behind a feature check.
+var codePatterns = []codePattern{
+	{RuleEval, SeverityWarning, regexp.MustCompile(`(?:^|[^\w$.])eval\s*\(`), "eval( runs code from a string"},
+	{RuleEval, SeverityWarning, regexp.MustCompile(`\bnew\s+Function\s*\(`), "new Function( runs code from a string"},
+	{RuleRemoteCode, SeverityError, regexp.MustCompile("\\b(?:import|importScripts)\\s*\\(\\s*[\"'`](?:https?:)?//"), "import of a remote url loads remotely hosted code"},
+	{RuleRemoteCode, SeverityError, regexp.MustCompile(`(?i)<script\b[^>]*\bsrc\s*=\s*["']?(?:https?:)?//`), "a script tag with a remote src loads remotely hosted code"},
+}
+
+// scriptExts are the files checkCode scans, html for inline scripts
+var scriptExts = map[string]bool{".js": true, ".mjs": true, ".cjs": true, ".html": true, ".htm": true}
+
+func (csp *contentSecurity) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		csp.ExtensionPages = single
+		return nil
+	}
+	var policies struct {
+		ExtensionPages string `json:"extension_pages"`
+		Sandbox        string `json:"sandbox"`
+	}
+	err := json.Unmarshal(data, &policies)
+	*csp = contentSecurity(policies)
+	return err
+}
+
+// checkCSP checks that the policies do not allow code from strings or from
+// other hosts. Manifest v3 extension pages can not have either, so they are
+// errors there. Sandboxed pages may eval, but a remote script is still
+// remotely hosted code.
+func (lint *linter) checkCSP() {
+	severity, key := SeverityWarning, "content_security_policy"
+	if lint.manifest.ManifestVersion >= 3 {
+		severity, key = SeverityError, key+".extension_pages"
+	}
+	csp := lint.manifest.ContentSecurityPolicy
+	for _, src := range scriptSources(csp.ExtensionPages) {
+		switch lower := strings.ToLower(src); {
+		case lower == "'unsafe-eval'" || lower == "'unsafe-inline'":
+			lint.add(severity, RuleCSP, "manifest.json", "%v allows %v in script-src, the store rejects code that is run from strings", key, src)
+		case isRemoteSource(lower):
+			lint.add(severity, RuleCSP, "manifest.json", "%v allows scripts from %v, the store rejects remotely hosted code", key, src)
+		}
+	}
+	for _, src := range scriptSources(csp.Sandbox) {
+		if isRemoteSource(strings.ToLower(src)) {
+			lint.add(severity, RuleCSP, "manifest.json", "content_security_policy.sandbox allows scripts from %v, the store rejects remotely hosted code", src)
+		}
+	}
+}
+
+// checkCode scans the scripts of the package for code that is run from
+// strings or loaded from other hosts, each pattern is reported once per file
+func (lint *linter) checkCode() {
+	for _, name := range lint.pkg.Paths() {
+		if !scriptExts[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+		data := lint.pkg.Files[name]
+		for _, pattern := range codePatterns {
+			matches := pattern.expr.FindAllIndex(data, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			line := bytes.Count(data[:matches[0][0]], []byte("\n")) + 1
+			if len(matches) > 1 {
+				lint.add(pattern.severity, pattern.rule, name, "line %v: %v, %v times in the file", line, pattern.msg, len(matches))
+			} else {
+				lint.add(pattern.severity, pattern.rule, name, "line %v: %v", line, pattern.msg)
+			}
+		}
+	}
+}
+
+// scriptSources are the sources of script-src and script-src-elem in a
+// policy, or of default-src when neither is set
+func scriptSources(policy string) []string {
+	directives := map[string][]string{}
+	for _, directive := range strings.Split(policy, ";") {
+		if fields := strings.Fields(directive); len(fields) > 0 {
+			name := strings.ToLower(fields[0])
+			directives[name] = append(directives[name], fields[1:]...)
+		}
+	}
+	sources := append(directives["script-src"], directives["script-src-elem"]...)
+	if len(sources) == 0 {
+		return directives["default-src"]
+	}
+	return sources
+}
+
+// isRemoteSource is true for a source that is a host or a scheme of the web,
+// keywords, nonces and hashes are quoted and local schemes are allowed
+func isRemoteSource(src string) bool {
+	if strings.HasPrefix(src, "'") || strings.HasPrefix(src, "chrome-extension:") {
+		return false
+	}
+	switch src {
+	case "blob:", "filesystem:", "data:", "mediastream:":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tanema/cws/lib/archive"
+)
+
+func TestCheckCSP(t *testing.T) {
+	for name, test := range map[string]struct {
+		manifest string
+		findings []Finding
+	}{
+		"mv3 default": {
+			manifest: `{"manifest_version": 3, "content_security_policy": {"extension_pages": "script-src 'self' 'wasm-unsafe-eval'; object-src 'self'"}}`,
+			findings: []Finding{},
+		},
+		"mv3 unsafe": {
+			manifest: `{"manifest_version": 3, "content_security_policy": {
+				"extension_pages": "script-src 'self' 'unsafe-eval' https://cdn.example.com; object-src 'self'",
+				"sandbox": "sandbox allow-scripts; script-src 'self' 'unsafe-eval' https:"
+			}}`,
+			findings: []Finding{
+				{Rule: RuleCSP, Severity: SeverityError, Path: "manifest.json", Message: "content_security_policy.extension_pages allows 'unsafe-eval' in script-src, the store rejects code that is run from strings"},
+				{Rule: RuleCSP, Severity: SeverityError, Path: "manifest.json", Message: "content_security_policy.extension_pages allows scripts from https://cdn.example.com, the store rejects remotely hosted code"},
+				{Rule: RuleCSP, Severity: SeverityError, Path: "manifest.json", Message: "content_security_policy.sandbox allows scripts from https:, the store rejects remotely hosted code"},
+			},
+		},
+		"mv2 default-src": {
+			manifest: `{"manifest_version": 2, "content_security_policy": "default-src 'self' blob: *.example.com"}`,
+			findings: []Finding{
+				{Rule: RuleCSP, Severity: SeverityWarning, Path: "manifest.json", Message: "content_security_policy allows scripts from *.example.com, the store rejects remotely hosted code"},
+			},
+		},
+	} {
+		pkg := &archive.Package{Files: map[string][]byte{"manifest.json": []byte(test.manifest)}}
+		assert.Equal(t, test.findings, Check(pkg).Findings, name)
+	}
+}
+
+func TestCheckCode(t *testing.T) {
+	pkg := &archive.Package{Files: map[string][]byte{
+		"manifest.json": []byte(`{"manifest_version": 3}`),
+		"bg.js": []byte(`const x = 1
+const run = (s) => eval(s)
+run("1"); eval("2"); obj.eval(3); retrieval(4)
+import('https://cdn.example.com/mod.js')
+const m = import('./local.js')
+`),
+		"vendor/lib.js": []byte(`var g = new Function("return this")()`),
+		"popup.html":    []byte(`<script src="popup.js"></script><SCRIPT type="module" src="//cdn.example.com/a.js"></SCRIPT>`),
+		"style.css":     []byte(`/* eval(x) */`),
+	}}
+	assert.Equal(t, []Finding{
+		{Rule: RuleEval, Severity: SeverityWarning, Path: "bg.js", Message: "line 2: eval( runs code from a string, 2 times in the file"},
+		{Rule: RuleRemoteCode, Severity: SeverityError, Path: "bg.js", Message: "line 4: import of a remote url loads remotely hosted code"},
+		{Rule: RuleRemoteCode, Severity: SeverityError, Path: "popup.html", Message: "line 1: a script tag with a remote src loads remotely hosted code"},
+		{Rule: RuleEval, Severity: SeverityWarning, Path: "vendor/lib.js", Message: "line 1: new Function( runs code from a string"},
+	}, Check(pkg).Findings)
+}
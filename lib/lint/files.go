@@ -0,0 +1,226 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tanema/cws/lib/archive"
+)
+
+type (
+	// manifestRefs are the parts of the manifest that refer to files
+	manifestRefs struct {
+		ManifestVersion       int               `json:"manifest_version"`
+		ContentSecurityPolicy contentSecurity   `json:"content_security_policy"`
+		DefaultLocale         string            `json:"default_locale"`
+		Icons                 map[string]string `json:"icons"`
+		Action                *action           `json:"action"`
+		BrowserAction         *action           `json:"browser_action"`
+		PageAction            *action           `json:"page_action"`
+		Background            struct {
+			ServiceWorker string   `json:"service_worker"`
+			Scripts       []string `json:"scripts"`
+			Page          string   `json:"page"`
+		} `json:"background"`
+		ContentScripts []struct {
+			JS  []string `json:"js"`
+			CSS []string `json:"css"`
+		} `json:"content_scripts"`
+		WebAccessibleResources []webResource `json:"web_accessible_resources"`
+		OptionsPage            string        `json:"options_page"`
+		OptionsUI              struct {
+			Page string `json:"page"`
+		} `json:"options_ui"`
+		DevtoolsPage string `json:"devtools_page"`
+		SidePanel    struct {
+			DefaultPath string `json:"default_path"`
+		} `json:"side_panel"`
+		ChromeURLOverrides map[string]string `json:"chrome_url_overrides"`
+		Sandbox            struct {
+			Pages []string `json:"pages"`
+		} `json:"sandbox"`
+	}
+	action struct {
+		DefaultIcon  icons  `json:"default_icon"`
+		DefaultPopup string `json:"default_popup"`
+	}
+	// icons is a single icon or icons by size
+	icons map[string]string
+	// webResource is a manifest v3 entry with resources, or a manifest v2 path
+	webResource struct {
+		Resources []string `json:"resources"`
+	}
+	// reference is a path that the manifest refers to under key
+	reference struct {
+		key  string
+		path string
+		// size is the width and height an icon is declared with
+		size int
+	}
+)
+
+func (icon *icons) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*icon = icons{"": single}
+		return nil
+	}
+	return json.Unmarshal(data, (*map[string]string)(icon))
+}
+
+func (res *webResource) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		res.Resources = []string{single}
+		return nil
+	}
+	var entry struct {
+		Resources []string `json:"resources"`
+	}
+	err := json.Unmarshal(data, &entry)
+	res.Resources = entry.Resources
+	return err
+}
+
+// checkFiles checks that every file the manifest refers to is in the package
+// and that icons are pngs of the size they are declared with
+func (lint *linter) checkFiles() {
+	for _, ref := range lint.manifest.references() {
+		name, ok := cleanPath(ref.path)
+		if !ok {
+			lint.error(RuleInvalidPath, ref.path, "%v must be a path inside of the package", ref.key)
+			continue
+		} else if strings.Contains(name, "*") {
+			lint.checkGlob(ref.key, name)
+			continue
+		}
+		data, ok := lint.pkg.Files[name]
+		if !ok {
+			lint.error(RuleMissingFile, name, "%v refers to %v which is not in the package%v", ref.key, name, lint.missingHint(name))
+		} else if ref.size >= 0 {
+			lint.checkIcon(ref, name, data)
+		}
+	}
+}
+
+// checkGlob checks that a web accessible resource pattern matches a file
+func (lint *linter) checkGlob(key, pattern string) {
+	expr := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+	for name := range lint.pkg.Files {
+		if expr.MatchString(name) {
+			return
+		}
+	}
+	lint.warn(RuleMissingFile, pattern, "%v has the pattern %v which matches no file in the package", key, pattern)
+}
+
+func (lint *linter) checkIcon(ref reference, name string, data []byte) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		lint.error(RuleIcon, name, "%v is not a valid png: %v", ref.key, err)
+	} else if ref.size > 0 && (config.Width != ref.size || config.Height != ref.size) {
+		lint.error(RuleIcon, name, "%v is declared as %vx%v but the image is %vx%v", ref.key, ref.size, ref.size, config.Width, config.Height)
+	}
+}
+
+// missingHint explains why a file is missing, when it was ignored or when
+// only the case of the name is wrong
+func (lint *linter) missingHint(name string) string {
+	if hint := lint.ignoredHint(name); hint != "" {
+		return hint
+	}
+	for other := range lint.pkg.Files {
+		if strings.EqualFold(other, name) {
+			return ", did you mean " + other + "? paths are case sensitive"
+		}
+	}
+	return ""
+}
+
+func (lint *linter) ignoredHint(name string) string {
+	if lint.pkg.IsIgnored(name) {
+		return ", it is excluded by " + archive.IgnoreFile
+	}
+	return ""
+}
+
+// references lists every path the manifest refers to, in a stable order.
+// Icons have a size, 0 when it is not declared, other files have -1.
+func (refs manifestRefs) references() []reference {
+	list := []reference{}
+	add := func(key string, paths ...string) {
+		for _, p := range paths {
+			if p != "" {
+				list = append(list, reference{key: key, path: p, size: -1})
+			}
+		}
+	}
+	addIcons := func(key string, icons map[string]string) {
+		sizes := make([]string, 0, len(icons))
+		for size := range icons {
+			sizes = append(sizes, size)
+		}
+		sort.Slice(sizes, func(i, j int) bool {
+			a, _ := strconv.Atoi(sizes[i])
+			b, _ := strconv.Atoi(sizes[j])
+			return a < b
+		})
+		for _, size := range sizes {
+			ref := reference{key: key, path: icons[size]}
+			if size != "" {
+				ref.key = key + "." + size
+				ref.size, _ = strconv.Atoi(size)
+			}
+			list = append(list, ref)
+		}
+	}
+
+	addIcons("icons", refs.Icons)
+	for _, act := range []struct {
+		key string
+		*action
+	}{{"action", refs.Action}, {"browser_action", refs.BrowserAction}, {"page_action", refs.PageAction}} {
+		if act.action != nil {
+			addIcons(act.key+".default_icon", act.DefaultIcon)
+			add(act.key+".default_popup", act.DefaultPopup)
+		}
+	}
+	add("background.service_worker", refs.Background.ServiceWorker)
+	add("background.scripts", refs.Background.Scripts...)
+	add("background.page", refs.Background.Page)
+	for _, script := range refs.ContentScripts {
+		add("content_scripts.js", script.JS...)
+		add("content_scripts.css", script.CSS...)
+	}
+	for _, res := range refs.WebAccessibleResources {
+		add("web_accessible_resources", res.Resources...)
+	}
+	add("options_page", refs.OptionsPage)
+	add("options_ui.page", refs.OptionsUI.Page)
+	add("devtools_page", refs.DevtoolsPage)
+	add("side_panel.default_path", refs.SidePanel.DefaultPath)
+	for _, page := range []string{"newtab", "history", "bookmarks"} {
+		add("chrome_url_overrides."+page, refs.ChromeURLOverrides[page])
+	}
+	add("sandbox.pages", refs.Sandbox.Pages...)
+	return list
+}
+
+// cleanPath makes a manifest path relative to the root of the package, paths
+// may start with a slash but must not leave the package
+func cleanPath(name string) (string, bool) {
+	if strings.Contains(name, "://") || strings.Contains(name, `\`) {
+		return "", false
+	}
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,204 @@
+package lint
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/manifest"
+)
+
+// Rules of CheckI18n
+const (
+	RuleInvalidMessages    = "invalid-messages"
+	RuleMissingTranslation = "missing-translation"
+	RuleStaleTranslation   = "stale-translation"
+	RuleUnusedMessage      = "unused-message"
+	RulePlaceholder        = "placeholder"
+	RuleLength             = "length"
+)
+
+// getMessageRef matches chrome.i18n.getMessage calls with a literal name
+var getMessageRef = regexp.MustCompile("getMessage\\(\\s*[\"'`]([A-Za-z0-9_@]+)[\"'`]")
+
+// codeExts are the files searched for messages that are in use
+var codeExts = map[string]bool{".js": true, ".mjs": true, ".html": true, ".htm": true, ".css": true}
+
+type (
+	// localized are the manifest fields that the store shows per locale
+	localized struct {
+		Name        string `json:"name"`
+		ShortName   string `json:"short_name"`
+		Description string `json:"description"`
+	}
+	// lengthLimit is the longest a localized field can be, too long short
+	// names are cut by chrome so they are only a warning
+	lengthLimit struct {
+		field    string
+		value    func(localized) string
+		max      int
+		severity string
+	}
+)
+
+var lengthLimits = []lengthLimit{
+	{"name", func(l localized) string { return l.Name }, manifest.MaxNameLength, SeverityError},
+	{"short_name", func(l localized) string { return l.ShortName }, manifest.MaxShortNameLength, SeverityWarning},
+	{"description", func(l localized) string { return l.Description }, manifest.MaxDescriptionLength, SeverityError},
+}
+
+// CheckI18n checks the messages.json of every locale: that it is valid, that
+// it translates the messages of the default locale and no others, that the
+// placeholders match and that the localized name and description fit in the
+// store limits. Messages of the default locale that are not used by the
+// manifest, or by a getMessage call with a literal name, are reported too.
+func CheckI18n(pkg *archive.Package) *Report {
+	lint := &linter{pkg: pkg, findings: []Finding{}}
+	lint.checkI18n()
+	return lint.report()
+}
+
+func (lint *linter) checkI18n() {
+	data, err := lint.pkg.Manifest()
+	if err != nil {
+		lint.error(RuleManifest, "manifest.json", "%v", err)
+		return
+	}
+	var fields localized
+	if err := json.Unmarshal(data, &lint.manifest); err != nil {
+		lint.error(RuleManifest, "manifest.json", "%v", &manifest.ValidationError{Msg: "error unmarshalling manifest", Err: err})
+		return
+	}
+	json.Unmarshal(data, &fields)
+
+	locales := lint.locales()
+	defaultLocale := lint.manifest.DefaultLocale
+	if len(locales) == 0 {
+		if defaultLocale != "" {
+			lint.error(RuleDefaultLocale, manifest.LocalesDir, "default_locale is %q but the package has no locales", defaultLocale)
+		}
+		lint.checkLengths("", fields, nil, nil)
+		return
+	} else if defaultLocale == "" {
+		lint.error(RuleDefaultLocale, manifest.LocalesDir, "%v has %v locales but the manifest has no default_locale", manifest.LocalesDir, len(locales))
+		return
+	}
+
+	all := map[string]manifest.Messages{}
+	for _, locale := range locales {
+		msgs, err := manifest.ParseMessages(lint.pkg.Files[manifest.MessagesPath(locale)])
+		if err != nil {
+			lint.error(RuleInvalidMessages, manifest.MessagesPath(locale), "%v", err)
+			continue
+		}
+		all[locale] = msgs
+		lint.checkPlaceholders(locale, msgs)
+	}
+	defaults, ok := all[defaultLocale]
+	if !ok {
+		if _, exists := lint.pkg.Files[manifest.MessagesPath(defaultLocale)]; !exists {
+			lint.error(RuleDefaultLocale, manifest.MessagesPath(defaultLocale), "default_locale is %q but there is no %v", defaultLocale, manifest.MessagesPath(defaultLocale))
+		}
+		return
+	}
+	for _, locale := range locales {
+		if msgs, ok := all[locale]; ok {
+			if locale != defaultLocale {
+				lint.compareLocale(locale, msgs, defaultLocale, defaults)
+			}
+			lint.checkLengths(locale, fields, msgs, defaults)
+		}
+	}
+	lint.checkUnused(data, defaultLocale, defaults)
+}
+
+// checkPlaceholders checks that every $name$ a message uses is defined
+func (lint *linter) checkPlaceholders(locale string, msgs manifest.Messages) {
+	for _, name := range sortedNames(msgs) {
+		defined := map[string]bool{}
+		for _, placeholder := range msgs[name].PlaceholderNames() {
+			defined[placeholder] = true
+		}
+		for _, ref := range msgs[name].PlaceholderRefs() {
+			if !defined[ref] {
+				lint.error(RulePlaceholder, manifest.MessagesPath(locale), "%q uses $%v$ but does not define it in placeholders", name, ref)
+			}
+		}
+	}
+}
+
+// compareLocale checks a translation against the default locale
+func (lint *linter) compareLocale(locale string, msgs manifest.Messages, defaultLocale string, defaults manifest.Messages) {
+	msgsPath := manifest.MessagesPath(locale)
+	for _, name := range sortedNames(defaults) {
+		msg, ok := msgs.Lookup(name)
+		if !ok {
+			lint.warn(RuleMissingTranslation, msgsPath, "%q is not translated, chrome shows the %v message", name, defaultLocale)
+			continue
+		}
+		want, got := defaults[name].PlaceholderNames(), msg.PlaceholderNames()
+		if strings.Join(want, ",") != strings.Join(got, ",") {
+			lint.error(RulePlaceholder, msgsPath, "%q has the placeholders [%v] but the %v message has [%v]", name, strings.Join(got, ", "), defaultLocale, strings.Join(want, ", "))
+		}
+	}
+	for _, name := range sortedNames(msgs) {
+		if _, ok := defaults.Lookup(name); !ok {
+			lint.warn(RuleStaleTranslation, msgsPath, "%q is not a message of the default locale %v", name, defaultLocale)
+		}
+	}
+}
+
+// checkLengths checks the localized name and description against the limits
+// of the store, messages missing from the locale fall back to the default
+func (lint *linter) checkLengths(locale string, fields localized, msgs, defaults manifest.Messages) {
+	filePath := "manifest.json"
+	if locale != "" {
+		filePath = manifest.MessagesPath(locale)
+	}
+	for _, limit := range lengthLimits {
+		value := manifest.Localize(limit.value(fields), msgs, defaults)
+		if length := utf8.RuneCountInString(value); length > limit.max {
+			msg := "%v is %v characters, the limit is %v"
+			if locale != "" {
+				msg = "the " + locale + " " + msg
+			}
+			lint.add(limit.severity, RuleLength, filePath, msg, limit.field, length, limit.max)
+		}
+	}
+}
+
+// checkUnused reports messages of the default locale that nothing refers to,
+// names built at runtime can not be found so these are only warnings
+func (lint *linter) checkUnused(manifestData []byte, defaultLocale string, defaults manifest.Messages) {
+	used := map[string]bool{}
+	refs, _ := manifest.MessageRefs(manifestData)
+	for name, data := range lint.pkg.Files {
+		if codeExts[strings.ToLower(path.Ext(name))] {
+			refs = append(refs, manifest.TextMessageRefs(data)...)
+			for _, match := range getMessageRef.FindAllSubmatch(data, -1) {
+				refs = append(refs, string(match[1]))
+			}
+		}
+	}
+	for _, ref := range refs {
+		used[strings.ToLower(ref)] = true
+	}
+	for _, name := range sortedNames(defaults) {
+		if !used[strings.ToLower(name)] {
+			lint.warn(RuleUnusedMessage, manifest.MessagesPath(defaultLocale), "%q is not used by the manifest or by a getMessage call", name)
+		}
+	}
+}
+
+func sortedNames(msgs manifest.Messages) []string {
+	names := make([]string, 0, len(msgs))
+	for name := range msgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
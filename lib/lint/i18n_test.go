@@ -0,0 +1,74 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tanema/cws/lib/archive"
+)
+
+func TestCheckI18nValid(t *testing.T) {
+	pkg := &archive.Package{Source: "dist", Files: map[string][]byte{
+		"manifest.json": []byte(`{"name": "__MSG_appName__", "description": "__MSG_appDesc__", "default_locale": "en"}`),
+		"popup.js":      []byte(`el.textContent = chrome.i18n.getMessage('greeting', [user])`),
+		"popup.css":     []byte(`body { direction: __MSG_@@bidi_dir__; }`),
+		"_locales/en/messages.json": []byte(`{
+			"appName": {"message": "Ext"},
+			"appDesc": {"message": "Does things"},
+			"greeting": {"message": "Hi $user$", "placeholders": {"user": {"content": "$1"}}}
+		}`),
+		"_locales/de/messages.json": []byte(`{
+			"appname": {"message": "Erweiterung"},
+			"appDesc": {"message": "Macht Dinge"},
+			"greeting": {"message": "Hallo $USER$", "placeholders": {"USER": {"content": "$1"}}}
+		}`),
+	}}
+	assert.Equal(t, &Report{Source: "dist", Findings: []Finding{}}, CheckI18n(pkg))
+}
+
+func TestCheckI18n(t *testing.T) {
+	pkg := &archive.Package{Files: map[string][]byte{
+		"manifest.json": []byte(`{"name": "__MSG_appName__", "short_name": "__MSG_short__", "description": "__MSG_appDesc__", "default_locale": "en"}`),
+		"_locales/en/messages.json": []byte(`{
+			"appName": {"message": "Ext"},
+			"short": {"message": "Ext"},
+			"appDesc": {"message": "Does things"},
+			"count": {"message": "$n$ items $total$", "placeholders": {"n": {"content": "$1"}}},
+			"unused": {"message": "never shown"}
+		}`),
+		"_locales/de/messages.json": []byte(`{
+			"appName": {"message": "Erweiterung"},
+			"short": {"message": "Meine Erweiterung"},
+			"appDesc": {"message": "` + strings.Repeat("x", 133) + `"},
+			"count": {"message": "$n$ Dinge", "placeholders": {"n": {"content": "$1"}, "m": {"content": "$2"}}},
+			"extra": {"message": "old"}
+		}`),
+		"_locales/fr/messages.json": []byte(`{"appName": `),
+	}}
+	report := CheckI18n(pkg)
+	assert.Equal(t, []Finding{
+		{Rule: RulePlaceholder, Severity: SeverityError, Path: "_locales/en/messages.json", Message: `"count" uses $total$ but does not define it in placeholders`},
+		{Rule: RuleInvalidMessages, Severity: SeverityError, Path: "_locales/fr/messages.json", Message: "error unmarshalling messages: unexpected end of JSON input"},
+		{Rule: RulePlaceholder, Severity: SeverityError, Path: "_locales/de/messages.json", Message: `"count" has the placeholders [m, n] but the en message has [n]`},
+		{Rule: RuleMissingTranslation, Severity: SeverityWarning, Path: "_locales/de/messages.json", Message: `"unused" is not translated, chrome shows the en message`},
+		{Rule: RuleStaleTranslation, Severity: SeverityWarning, Path: "_locales/de/messages.json", Message: `"extra" is not a message of the default locale en`},
+		{Rule: RuleLength, Severity: SeverityWarning, Path: "_locales/de/messages.json", Message: "the de short_name is 17 characters, the limit is 12"},
+		{Rule: RuleLength, Severity: SeverityError, Path: "_locales/de/messages.json", Message: "the de description is 133 characters, the limit is 132"},
+		{Rule: RuleUnusedMessage, Severity: SeverityWarning, Path: "_locales/en/messages.json", Message: `"count" is not used by the manifest or by a getMessage call`},
+		{Rule: RuleUnusedMessage, Severity: SeverityWarning, Path: "_locales/en/messages.json", Message: `"unused" is not used by the manifest or by a getMessage call`},
+	}, report.Findings)
+	assert.Equal(t, 4, report.Errors)
+	assert.Equal(t, 5, report.Warnings)
+}
+
+func TestCheckI18nWithoutLocales(t *testing.T) {
+	pkg := &archive.Package{Files: map[string][]byte{
+		"manifest.json": []byte(`{"name": "` + strings.Repeat("é", 76) + `", "default_locale": "en"}`),
+	}}
+	assert.Equal(t, []Finding{
+		{Rule: RuleDefaultLocale, Severity: SeverityError, Path: "_locales", Message: `default_locale is "en" but the package has no locales`},
+		{Rule: RuleLength, Severity: SeverityError, Path: "manifest.json", Message: "name is 76 characters, the limit is 75"},
+	}, CheckI18n(pkg).Findings)
+}
@@ -0,0 +1,199 @@
+// Package lint checks the package of an extension for problems that would
+// otherwise only show up after review or at install, like files that the
+// manifest refers to but that are missing from the archive.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tanema/cws/lib/archive"
+	"github.com/tanema/cws/lib/manifest"
+)
+
+// Severities of findings, errors fail the lint
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rules that findings are reported under
+const (
+	RuleManifest       = "manifest"
+	RuleMissingFile    = "missing-file"
+	RuleInvalidPath    = "invalid-path"
+	RuleIcon           = "icon"
+	RuleDefaultLocale  = "default-locale"
+	RuleMissingMessage = "missing-message"
+	RuleCSP            = "csp"
+	RuleEval           = "eval"
+	RuleRemoteCode     = "remote-code"
+)
+
+type (
+	// Finding is a single problem found in the package
+	Finding struct {
+		Rule     string `json:"rule"`
+		Severity string `json:"severity"`
+		// Path is the file the finding is about, if any
+		Path    string `json:"path,omitempty"`
+		Message string `json:"message"`
+	}
+	// Report is every finding of a package
+	Report struct {
+		Source   string `json:"source"`
+		Errors   int    `json:"errors"`
+		Warnings int    `json:"warnings"`
+		// Suppressed counts the findings left out by Suppress
+		Suppressed int       `json:"suppressed,omitempty"`
+		Findings   []Finding `json:"findings"`
+	}
+	// linter collects the findings of a package
+	linter struct {
+		pkg      *archive.Package
+		manifest manifestRefs
+		findings []Finding
+	}
+)
+
+// Check lints a package, a directory is checked as it would be archived so
+// files left out by the ignore file count as missing
+func Check(pkg *archive.Package) *Report {
+	lint := &linter{pkg: pkg, findings: []Finding{}}
+	lint.check()
+	return lint.report()
+}
+
+func (lint *linter) check() {
+	data, err := lint.pkg.Manifest()
+	if err != nil {
+		lint.error(RuleManifest, "manifest.json", "%v", err)
+		return
+	} else if err := json.Unmarshal(data, &lint.manifest); err != nil {
+		lint.error(RuleManifest, "manifest.json", "%v", &manifest.ValidationError{Msg: "error unmarshalling manifest", Err: err})
+		return
+	}
+	lint.checkFiles()
+	lint.checkLocales(data)
+	lint.checkCSP()
+	lint.checkCode()
+}
+
+// report counts the findings by severity
+func (lint *linter) report() *Report {
+	report := &Report{Source: lint.pkg.Source, Findings: lint.findings}
+	report.count()
+	return report
+}
+
+func (report *Report) count() {
+	report.Errors, report.Warnings = 0, 0
+	for _, finding := range report.Findings {
+		if finding.Severity == SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+}
+
+// Suppress leaves out the findings that match a pattern, a rule like "eval" or
+// a rule and a path like "eval:vendor/*". The path is a glob that matches the
+// file of the finding or a directory it is in.
+func (report *Report) Suppress(patterns []string) error {
+	findings := []Finding{}
+	for _, finding := range report.Findings {
+		suppressed, err := finding.matches(patterns)
+		if err != nil {
+			return err
+		} else if suppressed {
+			report.Suppressed++
+		} else {
+			findings = append(findings, finding)
+		}
+	}
+	report.Findings = findings
+	report.count()
+	return nil
+}
+
+func (finding Finding) matches(patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		rule, glob, hasPath := strings.Cut(pattern, ":")
+		if rule != finding.Rule && rule != "*" {
+			continue
+		} else if !hasPath {
+			return true, nil
+		}
+		for name := finding.Path; name != "." && name != "/" && name != ""; name = path.Dir(name) {
+			if ok, err := path.Match(glob, name); err != nil {
+				return false, fmt.Errorf("invalid lint suppression %q: %v", pattern, err)
+			} else if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (lint *linter) error(rule, path, msg string, args ...interface{}) {
+	lint.add(SeverityError, rule, path, msg, args...)
+}
+
+func (lint *linter) warn(rule, path, msg string, args ...interface{}) {
+	lint.add(SeverityWarning, rule, path, msg, args...)
+}
+
+func (lint *linter) add(severity, rule, path, msg string, args ...interface{}) {
+	lint.findings = append(lint.findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(msg, args...),
+	})
+}
+
+// checkLocales checks that default_locale and _locales go together and that
+// every message the manifest refers to is in the default locale
+func (lint *linter) checkLocales(data []byte) {
+	locales := lint.locales()
+	defaultLocale := lint.manifest.DefaultLocale
+	if defaultLocale == "" {
+		if len(locales) > 0 {
+			lint.error(RuleDefaultLocale, manifest.LocalesDir, "%v has %v locales but the manifest has no default_locale", manifest.LocalesDir, len(locales))
+		}
+		return
+	}
+	msgsPath := manifest.MessagesPath(defaultLocale)
+	msgsData, ok := lint.pkg.Files[msgsPath]
+	if !ok {
+		lint.error(RuleDefaultLocale, msgsPath, "default_locale is %q but there is no %v%v", defaultLocale, msgsPath, lint.ignoredHint(msgsPath))
+		return
+	}
+	msgs, err := manifest.ParseMessages(msgsData)
+	if err != nil {
+		lint.error(RuleDefaultLocale, msgsPath, "%v", err)
+		return
+	}
+	refs, _ := manifest.MessageRefs(data)
+	for _, ref := range refs {
+		if _, ok := msgs.Lookup(ref); !ok {
+			lint.error(RuleMissingMessage, msgsPath, "the manifest uses __MSG_%v__ but %v has no %q message", ref, defaultLocale, ref)
+		}
+	}
+}
+
+// locales lists the locales that have a messages.json
+func (lint *linter) locales() []string {
+	locales := []string{}
+	for name := range lint.pkg.Files {
+		if locale, ok := manifest.LocaleOf(name); ok {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
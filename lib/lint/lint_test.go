@@ -0,0 +1,138 @@
+package lint
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tanema/cws/lib/archive"
+)
+
+func pngData(t *testing.T, size int) []byte {
+	var buf bytes.Buffer
+	require.Nil(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, size, size))))
+	return buf.Bytes()
+}
+
+func TestCheckValid(t *testing.T) {
+	pkg := &archive.Package{Source: "dist", Files: map[string][]byte{
+		"manifest.json": []byte(`{
+			"name": "__MSG_appName__", "version": "1.0", "default_locale": "en",
+			"icons": {"16": "icons/16.png", "128": "/icons/128.png"},
+			"action": {"default_popup": "popup.html", "default_icon": {"16": "icons/16.png"}},
+			"background": {"service_worker": "bg.js"},
+			"content_scripts": [{"matches": ["<all_urls>"], "js": ["content.js"], "css": ["content.css"]}],
+			"web_accessible_resources": [{"resources": ["img/*"], "matches": ["<all_urls>"]}],
+			"options_ui": {"page": "options.html"}
+		}`),
+		"icons/16.png":              pngData(t, 16),
+		"icons/128.png":             pngData(t, 128),
+		"popup.html":                nil,
+		"bg.js":                     nil,
+		"content.js":                nil,
+		"content.css":               nil,
+		"img/logo.svg":              nil,
+		"options.html":              nil,
+		"_locales/en/messages.json": []byte(`{"APPNAME": {"message": "Ext"}}`),
+	}}
+	report := Check(pkg)
+	assert.Equal(t, &Report{Source: "dist", Findings: []Finding{}}, report)
+}
+
+func TestCheckFiles(t *testing.T) {
+	pkg := &archive.Package{Source: "dist", Files: map[string][]byte{
+		"manifest.json": []byte(`{
+			"name": "ext", "version": "1.0",
+			"icons": {"48": "icons/48.png", "128": "icons/128.png"},
+			"browser_action": {"default_icon": "icons/missing.png"},
+			"background": {"scripts": ["Bg.js", "vendor/lib.js"]},
+			"web_accessible_resources": ["fonts/*.woff"],
+			"options_page": "../options.html"
+		}`),
+		"icons/48.png":  pngData(t, 32),
+		"icons/128.png": []byte("GIF89a, not a png"),
+		"bg.js":         nil,
+	}, Ignored: []string{"vendor/"}}
+	report := Check(pkg)
+	assert.Equal(t, 6, report.Errors)
+	assert.Equal(t, 1, report.Warnings)
+	assert.Equal(t, []Finding{
+		{Rule: RuleIcon, Severity: SeverityError, Path: "icons/48.png", Message: "icons.48 is declared as 48x48 but the image is 32x32"},
+		{Rule: RuleIcon, Severity: SeverityError, Path: "icons/128.png", Message: "icons.128 is not a valid png: png: invalid format: not a PNG file"},
+		{Rule: RuleMissingFile, Severity: SeverityError, Path: "icons/missing.png", Message: "browser_action.default_icon refers to icons/missing.png which is not in the package"},
+		{Rule: RuleMissingFile, Severity: SeverityError, Path: "Bg.js", Message: "background.scripts refers to Bg.js which is not in the package, did you mean bg.js? paths are case sensitive"},
+		{Rule: RuleMissingFile, Severity: SeverityError, Path: "vendor/lib.js", Message: "background.scripts refers to vendor/lib.js which is not in the package, it is excluded by .cwsignore"},
+		{Rule: RuleMissingFile, Severity: SeverityWarning, Path: "fonts/*.woff", Message: "web_accessible_resources has the pattern fonts/*.woff which matches no file in the package"},
+		{Rule: RuleInvalidPath, Severity: SeverityError, Path: "../options.html", Message: "options_page must be a path inside of the package"},
+	}, report.Findings)
+}
+
+func TestCheckLocales(t *testing.T) {
+	for name, test := range map[string]struct {
+		files    map[string]string
+		findings []Finding
+	}{
+		"locales without default_locale": {
+			files: map[string]string{
+				"manifest.json":             `{"name": "ext"}`,
+				"_locales/en/messages.json": `{}`,
+			},
+			findings: []Finding{{Rule: RuleDefaultLocale, Severity: SeverityError, Path: "_locales", Message: "_locales has 1 locales but the manifest has no default_locale"}},
+		},
+		"default_locale without messages": {
+			files: map[string]string{
+				"manifest.json":             `{"name": "ext", "default_locale": "fr"}`,
+				"_locales/en/messages.json": `{}`,
+			},
+			findings: []Finding{{Rule: RuleDefaultLocale, Severity: SeverityError, Path: "_locales/fr/messages.json", Message: `default_locale is "fr" but there is no _locales/fr/messages.json`}},
+		},
+		"invalid messages": {
+			files: map[string]string{
+				"manifest.json":             `{"name": "ext", "default_locale": "en"}`,
+				"_locales/en/messages.json": `{`,
+			},
+			findings: []Finding{{Rule: RuleDefaultLocale, Severity: SeverityError, Path: "_locales/en/messages.json", Message: "error unmarshalling messages: unexpected end of JSON input"}},
+		},
+		"missing message": {
+			files: map[string]string{
+				"manifest.json":             `{"name": "__MSG_name__", "description": "__MSG_desc__", "default_locale": "en"}`,
+				"_locales/en/messages.json": `{"name": {"message": "Ext"}}`,
+			},
+			findings: []Finding{{Rule: RuleMissingMessage, Severity: SeverityError, Path: "_locales/en/messages.json", Message: `the manifest uses __MSG_desc__ but en has no "desc" message`}},
+		},
+		"invalid manifest": {
+			files:    map[string]string{"manifest.json": `{"icons": []}`},
+			findings: []Finding{{Rule: RuleManifest, Severity: SeverityError, Path: "manifest.json", Message: "error unmarshalling manifest: json: cannot unmarshal array into Go struct field manifestRefs.icons of type map[string]string"}},
+		},
+	} {
+		pkg := &archive.Package{Files: map[string][]byte{}}
+		for path, data := range test.files {
+			pkg.Files[path] = []byte(data)
+		}
+		assert.Equal(t, test.findings, Check(pkg).Findings, name)
+	}
+}
+
+func TestReportSuppress(t *testing.T) {
+	report := &Report{Findings: []Finding{
+		{Rule: RuleEval, Severity: SeverityWarning, Path: "vendor/lib/a.js"},
+		{Rule: RuleEval, Severity: SeverityWarning, Path: "bg.js"},
+		{Rule: RuleRemoteCode, Severity: SeverityError, Path: "vendor/lib/a.js"},
+		{Rule: RuleCSP, Severity: SeverityError, Path: "manifest.json"},
+	}}
+	report.count()
+	require.Nil(t, report.Suppress([]string{"eval:vendor/*", "csp"}))
+	assert.Equal(t, &Report{Errors: 1, Warnings: 1, Suppressed: 2, Findings: []Finding{
+		{Rule: RuleEval, Severity: SeverityWarning, Path: "bg.js"},
+		{Rule: RuleRemoteCode, Severity: SeverityError, Path: "vendor/lib/a.js"},
+	}}, report)
+
+	require.Nil(t, report.Suppress([]string{"*:vendor"}))
+	assert.Equal(t, 3, report.Suppressed)
+	assert.Equal(t, 0, report.Errors)
+	assert.EqualError(t, report.Suppress([]string{"eval:["}), `invalid lint suppression "eval:[": syntax error in pattern`)
+}
@@ -4,9 +4,84 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strconv"
 	"strings"
 )
 
+// ValidationError is returned when the manifest, the version or the changes to
+// the manifest are not valid
+type ValidationError struct {
+	Msg string
+	Err error
+}
+
+func (err *ValidationError) Error() string {
+	if err.Err == nil {
+		return err.Msg
+	}
+	return fmt.Sprintf("%v: %v", err.Msg, err.Err)
+}
+
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// ValidateVersion checks that the version is one to four dot separated integers
+// between 0 and 65535 as required by chrome
+func ValidateVersion(version string) error {
+	parts := strings.Split(version, ".")
+	if len(parts) > 4 {
+		return &ValidationError{Msg: fmt.Sprintf("invalid version %q, expected at most 4 parts", version)}
+	}
+	for _, part := range parts {
+		num, err := strconv.Atoi(part)
+		if err != nil || num < 0 || num > 65535 || (len(part) > 1 && part[0] == '0') {
+			return &ValidationError{Msg: fmt.Sprintf("invalid version %q, each part must be an integer between 0 and 65535 without leading zeros", version)}
+		}
+	}
+	return nil
+}
+
+// CompareVersions compares two valid versions part by part, returning -1 when a
+// is lower than b, 0 when they are equal and 1 when a is higher. Missing parts
+// count as 0 so 1.2 equals 1.2.0.
+func CompareVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum < bNum {
+			return -1
+		} else if aNum > bNum {
+			return 1
+		}
+	}
+	return 0
+}
+
+// NextVersion is the lowest version above version with the same number of
+// parts, or with a part added when the last part is already at its maximum
+func NextVersion(version string) (string, error) {
+	if err := ValidateVersion(version); err != nil {
+		return "", err
+	}
+	parts := strings.Split(version, ".")
+	last, _ := strconv.Atoi(parts[len(parts)-1])
+	if last < 65535 {
+		parts[len(parts)-1] = strconv.Itoa(last + 1)
+	} else if len(parts) < 4 {
+		parts = append(parts, "1")
+	} else {
+		return "", &ValidationError{Msg: fmt.Sprintf("there is no version above %v", version)}
+	}
+	return strings.Join(parts, "."), nil
+}
+
 func parseJSONChangeset(changeset string) (map[string]string, error) {
 	if changeset == "" {
 		return map[string]string{}, nil
@@ -15,7 +90,7 @@ func parseJSONChangeset(changeset string) (map[string]string, error) {
 	for _, change := range strings.Split(changeset, ",") {
 		parts := strings.Split(change, ":")
 		if len(parts) != 2 {
-			return nil, fmt.Errorf("malformed json change %v, expected key:value", change)
+			return nil, &ValidationError{Msg: fmt.Sprintf("malformed json change %v, expected key:value", change)}
 		}
 		set[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
 	}
@@ -23,19 +98,24 @@ func parseJSONChangeset(changeset string) (map[string]string, error) {
 }
 
 func UpdateBytes(path, version, jsonChangeset string) ([]byte, error) {
-	changeset, err := parseJSONChangeset(jsonChangeset)
+	manifestBytes, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("reading manifest: %w", err)
 	}
+	return UpdateData(manifestBytes, version, jsonChangeset)
+}
 
-	manifestBytes, err := ioutil.ReadFile(path)
+// UpdateData is the same as UpdateBytes for a manifest that was already read,
+// like one inside of an archive
+func UpdateData(manifestBytes []byte, version, jsonChangeset string) ([]byte, error) {
+	changeset, err := parseJSONChangeset(jsonChangeset)
 	if err != nil {
-		return nil, fmt.Errorf("reading manifest: %v", err)
+		return nil, err
 	}
 
 	manifest := map[string]interface{}{}
 	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
-		return nil, fmt.Errorf("error unmarshalling manifest: %v", err)
+		return nil, &ValidationError{Msg: "error unmarshalling manifest", Err: err}
 	}
 
 	delete(manifest, "key")
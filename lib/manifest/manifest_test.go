@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, CompareVersions("1.2", "1.2.0"))
+	assert.Equal(t, -1, CompareVersions("1.9", "1.10"))
+	assert.Equal(t, 1, CompareVersions("2", "1.65535.1"))
+	assert.Equal(t, -1, CompareVersions("23.4.1.7", "23.4.1.8"))
+}
+
+func TestNextVersion(t *testing.T) {
+	for version, next := range map[string]string{
+		"1":        "2",
+		"1.2.3":    "1.2.4",
+		"23.4.1.7": "23.4.1.8",
+		"1.65535":  "1.65535.1",
+	} {
+		got, err := NextVersion(version)
+		require.Nil(t, err)
+		assert.Equal(t, next, got)
+	}
+	_, err := NextVersion("1.2.3.65535")
+	assert.ErrorContains(t, err, "no version above")
+	_, err = NextVersion("1.x")
+	assert.ErrorContains(t, err, "invalid version")
+}
+
+func TestUpdateData(t *testing.T) {
+	data, err := UpdateData([]byte(`{"name": "ext", "version": "1.0", "key": "dev"}`), "1.1", "")
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"name": "ext", "version": "1.1"}`, string(data))
+
+	_, err = UpdateData([]byte(`{`), "1.1", "")
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
@@ -0,0 +1,162 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LocalesDir holds a messages.json for each locale, like _locales/en/messages.json
+const LocalesDir = "_locales"
+
+// Limits of the localized fields of the manifest, in characters
+const (
+	MaxNameLength        = 75
+	MaxShortNameLength   = 12
+	MaxDescriptionLength = 132
+)
+
+type (
+	// Messages are the translated strings of a locale by message name. Names
+	// are case insensitive, use Lookup to find one.
+	Messages map[string]Message
+	// Message is a translated string, $name$ in it is replaced by a placeholder
+	Message struct {
+		Message      string                 `json:"message"`
+		Description  string                 `json:"description,omitempty"`
+		Placeholders map[string]Placeholder `json:"placeholders,omitempty"`
+	}
+	// Placeholder is the content that replaces $name$ in a message
+	Placeholder struct {
+		Content string `json:"content"`
+		Example string `json:"example,omitempty"`
+	}
+)
+
+// messageRef matches __MSG_name__, names starting with @@ are predefined by
+// chrome and are not in messages.json
+var messageRef = regexp.MustCompile(`__MSG_([A-Za-z0-9_]+)__`)
+
+// placeholderRef matches $name$ in a message, $$ is a literal dollar sign
+var placeholderRef = regexp.MustCompile(`\$([A-Za-z0-9_@]+)\$`)
+
+// MessagesPath is the messages.json of a locale inside of a package
+func MessagesPath(locale string) string {
+	return LocalesDir + "/" + locale + "/messages.json"
+}
+
+// LocaleOf is the locale of a messages.json path inside of a package
+func LocaleOf(path string) (string, bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[0] != LocalesDir || parts[2] != "messages.json" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// ParseMessages reads a messages.json, which may start with a byte order mark
+func ParseMessages(data []byte) (Messages, error) {
+	msgs := Messages{}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &msgs); err != nil {
+		return nil, &ValidationError{Msg: "error unmarshalling messages", Err: err}
+	}
+	return msgs, nil
+}
+
+// Lookup finds a message by name, ignoring case like chrome does
+func (msgs Messages) Lookup(name string) (Message, bool) {
+	if msg, ok := msgs[name]; ok {
+		return msg, true
+	}
+	for key, msg := range msgs {
+		if strings.EqualFold(key, name) {
+			return msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// PlaceholderRefs lists the placeholders the message uses, lower cased as
+// placeholder names are case insensitive
+func (msg Message) PlaceholderRefs() []string {
+	text := strings.ReplaceAll(msg.Message, "$$", "")
+	refs := []string{}
+	for _, match := range placeholderRef.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, strings.ToLower(match[1]))
+	}
+	return uniqueSorted(refs)
+}
+
+// PlaceholderNames lists the placeholders the message defines, lower cased
+func (msg Message) PlaceholderNames() []string {
+	names := []string{}
+	for name := range msg.Placeholders {
+		names = append(names, strings.ToLower(name))
+	}
+	return uniqueSorted(names)
+}
+
+// Localize replaces the __MSG_name__ references in a manifest value with the
+// messages of a locale, or of the fallback locale when the locale does not
+// have them, like chrome does
+func Localize(value string, msgs, fallback Messages) string {
+	return messageRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := messageRef.FindStringSubmatch(ref)[1]
+		if msg, ok := msgs.Lookup(name); ok {
+			return msg.Message
+		} else if msg, ok := fallback.Lookup(name); ok {
+			return msg.Message
+		}
+		return ref
+	})
+}
+
+// TextMessageRefs lists the __MSG_name__ references in any text, like the
+// css and html files of a package
+func TextMessageRefs(text []byte) []string {
+	refs := []string{}
+	for _, match := range messageRef.FindAllSubmatch(text, -1) {
+		refs = append(refs, string(match[1]))
+	}
+	return uniqueSorted(refs)
+}
+
+// MessageRefs lists the names of the messages that the string values of the
+// manifest refer to with __MSG_name__, without duplicates
+func MessageRefs(manifestBytes []byte) ([]string, error) {
+	var parsed interface{}
+	if err := json.Unmarshal(manifestBytes, &parsed); err != nil {
+		return nil, &ValidationError{Msg: "error unmarshalling manifest", Err: err}
+	}
+	seen := map[string]bool{}
+	refs := []string{}
+	var visit func(val interface{})
+	visit = func(val interface{}) {
+		switch val := val.(type) {
+		case string:
+			for _, match := range messageRef.FindAllStringSubmatch(val, -1) {
+				if !seen[match[1]] {
+					seen[match[1]] = true
+					refs = append(refs, match[1])
+				}
+			}
+		case []interface{}:
+			for _, item := range val {
+				visit(item)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(val))
+			for key := range val {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				visit(val[key])
+			}
+		}
+	}
+	visit(parsed)
+	return refs, nil
+}
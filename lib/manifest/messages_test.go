@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMessages(t *testing.T) {
+	msgs, err := ParseMessages([]byte("\xef\xbb\xbf" + `{"appName": {"message": "Ext", "description": "the name"}}`))
+	require.Nil(t, err)
+	msg, ok := msgs.Lookup("APPNAME")
+	assert.True(t, ok, "names are case insensitive")
+	assert.Equal(t, Message{Message: "Ext", Description: "the name"}, msg)
+	_, ok = msgs.Lookup("other")
+	assert.False(t, ok)
+
+	_, err = ParseMessages([]byte(`{"appName": "Ext"}`))
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestMessageRefs(t *testing.T) {
+	refs, err := MessageRefs([]byte(`{
+		"name": "__MSG_appName__",
+		"description": "__MSG_appDesc__ by __MSG_@@extension_id__",
+		"action": {"default_title": "__MSG_appName__"},
+		"commands": {"open": {"description": "__MSG_open_cmd__"}}
+	}`))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"appName", "open_cmd", "appDesc"}, refs)
+}
+
+func TestLocaleOf(t *testing.T) {
+	locale, ok := LocaleOf("_locales/pt_BR/messages.json")
+	assert.True(t, ok)
+	assert.Equal(t, "pt_BR", locale)
+	_, ok = LocaleOf("_locales/pt_BR/other.json")
+	assert.False(t, ok)
+	assert.Equal(t, "_locales/en/messages.json", MessagesPath("en"))
+}
+
+func TestMessagePlaceholders(t *testing.T) {
+	msg := Message{
+		Message:      "$User$ paid $$5 for $item$, $user$",
+		Placeholders: map[string]Placeholder{"USER": {Content: "$1"}, "count": {Content: "$2"}},
+	}
+	assert.Equal(t, []string{"item", "user"}, msg.PlaceholderRefs())
+	assert.Equal(t, []string{"count", "user"}, msg.PlaceholderNames())
+}
+
+func TestLocalize(t *testing.T) {
+	msgs := Messages{"appName": {Message: "Erweiterung"}}
+	fallback := Messages{"appName": {Message: "Extension"}, "appDesc": {Message: "Does things"}}
+	assert.Equal(t, "Erweiterung: Does things", Localize("__MSG_APPNAME__: __MSG_appDesc__", msgs, fallback))
+	assert.Equal(t, "__MSG_missing__", Localize("__MSG_missing__", msgs, fallback))
+	assert.Equal(t, "plain", Localize("plain", nil, nil))
+}
+
+func TestTextMessageRefs(t *testing.T) {
+	refs := TextMessageRefs([]byte(`<h1>__MSG_title__</h1> body { direction: __MSG_@@bidi_dir__; } <p>__MSG_title__ __MSG_body__</p>`))
+	assert.Equal(t, []string{"body", "title"}, refs)
+}
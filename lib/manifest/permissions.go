@@ -0,0 +1,252 @@
+package manifest
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Permission fields, content scripts are listed by the patterns they match
+const (
+	FieldPermissions             = "permissions"
+	FieldOptionalPermissions     = "optional_permissions"
+	FieldHostPermissions         = "host_permissions"
+	FieldOptionalHostPermissions = "optional_host_permissions"
+	FieldContentScripts          = "content_scripts.matches"
+)
+
+type (
+	// Permissions is what a manifest asks access to. Host patterns listed in
+	// permissions, as manifest v2 does, are moved to the host fields so that a
+	// v2 and a v3 manifest can be compared.
+	Permissions struct {
+		Permissions             []string `json:"permissions,omitempty"`
+		OptionalPermissions     []string `json:"optional_permissions,omitempty"`
+		HostPermissions         []string `json:"host_permissions,omitempty"`
+		OptionalHostPermissions []string `json:"optional_host_permissions,omitempty"`
+		ContentScripts          []string `json:"content_scripts_matches,omitempty"`
+	}
+	// PermissionChange is a permission that was added to or removed from a
+	// field. Warning is what chrome asks users to accept before the update is
+	// enabled again, it is empty when the change shows no warning.
+	PermissionChange struct {
+		Field      string `json:"field"`
+		Permission string `json:"permission"`
+		Added      bool   `json:"added"`
+		Warning    string `json:"warning,omitempty"`
+	}
+)
+
+// warnings are the permissions that chrome shows a warning for, from
+// https://developer.chrome.com/docs/extensions/reference/permissions-list
+var warnings = map[string]string{
+	"accessibilityFeatures.modify":  "Change your accessibility settings",
+	"accessibilityFeatures.read":    "Read your accessibility settings",
+	"bookmarks":                     "Read and change your bookmarks",
+	"clipboardRead":                 "Read data you copy and paste",
+	"clipboardWrite":                "Modify data you copy and paste",
+	"contentSettings":               "Change your settings that control websites' access to features such as cookies, JavaScript, plugins, geolocation, microphone, camera etc.",
+	"debugger":                      "Access the page debugger backend",
+	"declarativeNetRequest":         "Block content on any page",
+	"declarativeNetRequestFeedback": "Read your browsing history",
+	"desktopCapture":                "Capture content of your screen",
+	"downloads":                     "Manage your downloads",
+	"downloads.open":                "Open downloaded files",
+	"favicon":                       "Read the icons of the websites you visit",
+	"geolocation":                   "Detect your physical location",
+	"history":                       "Read and change your browsing history on all your signed-in devices",
+	"identity.email":                "Know your email address",
+	"management":                    "Manage your apps, extensions, and themes",
+	"nativeMessaging":               "Communicate with cooperating native applications",
+	"notifications":                 "Display notifications",
+	"pageCapture":                   "Read and change all your data on all websites",
+	"privacy":                       "Change your privacy-related settings",
+	"proxy":                         "Read and change all your data on all websites",
+	"readingList":                   "Read and change entries in the reading list",
+	"sessions":                      "Read your browsing history on all your signed-in devices",
+	"system.storage":                "Identify and eject storage devices",
+	"tabCapture":                    "Read and change all your data on all websites",
+	"tabGroups":                     "View and manage your tab groups",
+	"tabs":                          "Read your browsing history",
+	"topSites":                      "Read a list of your most frequently visited websites",
+	"ttsEngine":                     "Read all text spoken using synthesized speech",
+	"webNavigation":                 "Read your browsing history",
+}
+
+// ParsePermissions reads the permissions of a manifest
+func ParsePermissions(manifestBytes []byte) (Permissions, error) {
+	var raw struct {
+		Permissions             []interface{} `json:"permissions"`
+		OptionalPermissions     []interface{} `json:"optional_permissions"`
+		HostPermissions         []string      `json:"host_permissions"`
+		OptionalHostPermissions []string      `json:"optional_host_permissions"`
+		ContentScripts          []struct {
+			Matches []string `json:"matches"`
+		} `json:"content_scripts"`
+	}
+	if err := json.Unmarshal(manifestBytes, &raw); err != nil {
+		return Permissions{}, &ValidationError{Msg: "error unmarshalling manifest", Err: err}
+	}
+	perms := Permissions{
+		HostPermissions:         raw.HostPermissions,
+		OptionalHostPermissions: raw.OptionalHostPermissions,
+	}
+	perms.Permissions, perms.HostPermissions = splitHosts(raw.Permissions, perms.HostPermissions)
+	perms.OptionalPermissions, perms.OptionalHostPermissions = splitHosts(raw.OptionalPermissions, perms.OptionalHostPermissions)
+	for _, script := range raw.ContentScripts {
+		perms.ContentScripts = append(perms.ContentScripts, script.Matches...)
+	}
+	perms.Permissions = unique(perms.Permissions)
+	perms.OptionalPermissions = unique(perms.OptionalPermissions)
+	perms.HostPermissions = unique(perms.HostPermissions)
+	perms.OptionalHostPermissions = unique(perms.OptionalHostPermissions)
+	perms.ContentScripts = unique(perms.ContentScripts)
+	return perms, nil
+}
+
+// DiffPermissions lists the permissions added and removed by the new manifest,
+// field by field. Added permissions that chrome shows a warning for get the
+// warning, optional permissions only warn when they are requested so they
+// never do, and hosts only warn when they are not already covered by a host
+// or content script pattern of the old manifest.
+func DiffPermissions(old, new Permissions) []PermissionChange {
+	oldHosts := append(append([]string{}, old.HostPermissions...), old.ContentScripts...)
+	hostWarning := func(host string) string {
+		if hostCovered(host, oldHosts) {
+			return ""
+		}
+		return HostWarning(host)
+	}
+	changes := []PermissionChange{}
+	for _, field := range []struct {
+		name     string
+		old, new []string
+		warning  func(string) string
+	}{
+		{FieldPermissions, old.Permissions, new.Permissions, Warning},
+		{FieldOptionalPermissions, old.OptionalPermissions, new.OptionalPermissions, nil},
+		{FieldHostPermissions, old.HostPermissions, new.HostPermissions, hostWarning},
+		{FieldOptionalHostPermissions, old.OptionalHostPermissions, new.OptionalHostPermissions, nil},
+		{FieldContentScripts, old.ContentScripts, new.ContentScripts, hostWarning},
+	} {
+		for _, perm := range missing(field.new, field.old) {
+			change := PermissionChange{Field: field.name, Permission: perm, Added: true}
+			if field.warning != nil {
+				change.Warning = field.warning(perm)
+			}
+			changes = append(changes, change)
+		}
+		for _, perm := range missing(field.old, field.new) {
+			changes = append(changes, PermissionChange{Field: field.name, Permission: perm})
+		}
+	}
+	return changes
+}
+
+// Warning is the warning chrome shows for an api permission, empty when it
+// shows none
+func Warning(perm string) string {
+	return warnings[perm]
+}
+
+// HostWarning is the warning chrome shows for access to the hosts of a match
+// pattern
+func HostWarning(pattern string) string {
+	_, host := parsePattern(pattern)
+	switch {
+	case host == "*":
+		return "Read and change all your data on all websites"
+	case host == "":
+		return "Read and change your local files"
+	case strings.HasPrefix(host, "*."):
+		return "Read and change your data on all " + host[2:] + " sites"
+	default:
+		return "Read and change your data on " + host
+	}
+}
+
+// hostCovered reports whether every url that pattern matches is matched by
+// one of the patterns of by, paths are ignored as chrome warns per host
+func hostCovered(pattern string, by []string) bool {
+	scheme, host := parsePattern(pattern)
+	for _, other := range by {
+		otherScheme, otherHost := parsePattern(other)
+		schemeOK := otherScheme == scheme ||
+			(otherScheme == "*" && (scheme == "http" || scheme == "https"))
+		hostOK := otherHost == "*" || otherHost == host ||
+			(strings.HasPrefix(otherHost, "*.") && (host == otherHost[2:] || strings.HasSuffix(host, otherHost[1:])))
+		if schemeOK && hostOK {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePattern is the scheme and host of a match pattern like
+// https://*.example.com/*, both are "*" for <all_urls>
+func parsePattern(pattern string) (scheme, host string) {
+	if pattern == "<all_urls>" {
+		return "*", "*"
+	}
+	scheme, rest, _ := strings.Cut(pattern, "://")
+	host, _, _ = strings.Cut(rest, "/")
+	return scheme, host
+}
+
+// splitHosts moves the host patterns out of a permissions list, some
+// permissions are objects, like usbDevices in v2, and are kept as json
+func splitHosts(list []interface{}, hosts []string) (perms, allHosts []string) {
+	allHosts = hosts
+	for _, perm := range list {
+		name, ok := perm.(string)
+		if !ok {
+			data, _ := json.Marshal(perm)
+			name = string(data)
+		}
+		if isHost(name) {
+			allHosts = append(allHosts, name)
+		} else {
+			perms = append(perms, name)
+		}
+	}
+	return perms, allHosts
+}
+
+func isHost(perm string) bool {
+	return perm == "<all_urls>" || strings.Contains(perm, "://")
+}
+
+// missing lists the values of a that are not in b
+func missing(a, b []string) []string {
+	set := map[string]bool{}
+	for _, val := range b {
+		set[val] = true
+	}
+	var out []string
+	for _, val := range a {
+		if !set[val] {
+			out = append(out, val)
+		}
+	}
+	return out
+}
+
+func unique(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	return uniqueSorted(list)
+}
+
+func uniqueSorted(list []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, val := range list {
+		if !seen[val] {
+			seen[val] = true
+			out = append(out, val)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
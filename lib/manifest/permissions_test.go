@@ -0,0 +1,60 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePermissions(t *testing.T) {
+	perms, err := ParsePermissions([]byte(`{
+		"permissions": ["tabs", "storage", "tabs", "<all_urls>", {"usbDevices": [{"vendorId": 1}]}],
+		"optional_permissions": ["https://example.com/*", "bookmarks"],
+		"host_permissions": ["https://*.google.com/*"],
+		"content_scripts": [{"matches": ["https://a.com/*"]}, {"matches": ["https://b.com/*", "https://a.com/*"]}]
+	}`))
+	require.Nil(t, err)
+	assert.Equal(t, Permissions{
+		Permissions:             []string{"storage", "tabs", `{"usbDevices":[{"vendorId":1}]}`},
+		OptionalPermissions:     []string{"bookmarks"},
+		HostPermissions:         []string{"<all_urls>", "https://*.google.com/*"},
+		OptionalHostPermissions: []string{"https://example.com/*"},
+		ContentScripts:          []string{"https://a.com/*", "https://b.com/*"},
+	}, perms)
+
+	_, err = ParsePermissions([]byte(`{`))
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestDiffPermissions(t *testing.T) {
+	old := Permissions{Permissions: []string{"storage", "tabs"}, ContentScripts: []string{"https://a.com/*"}}
+	new := Permissions{Permissions: []string{"storage", "history"}, HostPermissions: []string{"<all_urls>"}, ContentScripts: []string{"https://a.com/*"}}
+	assert.Equal(t, []PermissionChange{
+		{Field: FieldPermissions, Permission: "history", Added: true, Warning: "Read and change your browsing history on all your signed-in devices"},
+		{Field: FieldPermissions, Permission: "tabs"},
+		{Field: FieldHostPermissions, Permission: "<all_urls>", Added: true, Warning: "Read and change all your data on all websites"},
+	}, DiffPermissions(old, new))
+	assert.Empty(t, DiffPermissions(new, new))
+}
+
+func TestDiffPermissionsWarnings(t *testing.T) {
+	old := Permissions{HostPermissions: []string{"*://*.example.com/*"}, ContentScripts: []string{"https://a.com/app/*"}}
+	new := Permissions{
+		Permissions:             []string{"storage"},
+		OptionalPermissions:     []string{"tabs"},
+		HostPermissions:         []string{"*://*.example.com/*", "https://a.com/*", "https://b.com/*"},
+		OptionalHostPermissions: []string{"<all_urls>"},
+		ContentScripts:          []string{"https://a.com/app/*", "https://docs.example.com/*", "file:///*"},
+	}
+	assert.Equal(t, []PermissionChange{
+		{Field: FieldPermissions, Permission: "storage", Added: true},
+		{Field: FieldOptionalPermissions, Permission: "tabs", Added: true},
+		{Field: FieldHostPermissions, Permission: "https://a.com/*", Added: true},
+		{Field: FieldHostPermissions, Permission: "https://b.com/*", Added: true, Warning: "Read and change your data on b.com"},
+		{Field: FieldOptionalHostPermissions, Permission: "<all_urls>", Added: true},
+		{Field: FieldContentScripts, Permission: "https://docs.example.com/*", Added: true},
+		{Field: FieldContentScripts, Permission: "file:///*", Added: true, Warning: "Read and change your local files"},
+	}, DiffPermissions(old, new))
+}
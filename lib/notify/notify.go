@@ -0,0 +1,257 @@
+// Package notify posts release events, like an upload or a rejected publish,
+// to Slack, Microsoft Teams or any webhook. Payloads are text/templates
+// rendered with the event, each notifier type has a default template.
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tanema/cws/lib/term"
+)
+
+// Notifier types
+const (
+	TypeSlack   = "slack"
+	TypeTeams   = "teams"
+	TypeWebhook = "webhook"
+)
+
+// Events that notifiers can subscribe to
+const (
+	EventUpload   = "upload"
+	EventPublish  = "publish"
+	EventRejected = "rejected"
+	EventRollout  = "rollout"
+)
+
+// Events lists every event, notifiers without events get all of them
+var Events = []string{EventUpload, EventPublish, EventRejected, EventRollout}
+
+// Templates are the default payloads of each notifier type
+var Templates = map[string]string{
+	TypeSlack: `{"text": {{.Summary | json}}}`,
+	TypeTeams: `{
+  "@type": "MessageCard",
+  "@context": "https://schema.org/extensions",
+  "summary": {{.Summary | json}},
+  "themeColor": "{{if eq .Event "rejected"}}d32f2f{{else}}2e7d32{{end}}",
+  "title": {{.Summary | json}},
+  "sections": [{"facts": [
+    {"name": "Extension ID", "value": {{.ExtensionID | json}}},
+    {"name": "Version", "value": {{.Version | json}}},
+    {"name": "State", "value": {{.State | json}}}{{with .Errors}},
+    {"name": "Errors", "value": {{join "; " . | json}}}{{end}}
+  ]}]
+}`,
+	TypeWebhook: `{{. | json}}`,
+}
+
+type (
+	// Notifier is a destination for release events
+	Notifier struct {
+		Name string `json:"name"`
+		// Type is slack, teams or webhook
+		Type string `json:"type"`
+		URL  string `json:"url" secret:"true"`
+		// Events limits the events that are sent, all events by default
+		Events []string `json:"events,omitempty"`
+		// Template overrides the default payload of the type
+		Template string            `json:"template,omitempty"`
+		Headers  map[string]string `json:"headers,omitempty"`
+	}
+	// Event is something that happened to a version of an extension
+	Event struct {
+		Event string `json:"event"`
+		// Name is the name of the extension in a config with several extensions
+		Name             string    `json:"name,omitempty"`
+		ExtensionID      string    `json:"extension_id"`
+		Version          string    `json:"version,omitempty"`
+		State            string    `json:"state,omitempty"`
+		PublishTarget    string    `json:"publish_target,omitempty"`
+		DeployPercentage int       `json:"deploy_percentage,omitempty"`
+		Errors           []string  `json:"errors,omitempty"`
+		Time             time.Time `json:"time"`
+	}
+	// Sender delivers events to notifiers, retrying failed deliveries
+	Sender struct {
+		Client *http.Client
+		// Attempts is how many times a delivery is tried, defaults to 3
+		Attempts int
+		// Backoff is the wait before the first retry, doubled for every retry
+		Backoff time.Duration
+	}
+	// statusError is a response that was not a success
+	statusError struct {
+		code int
+		body string
+	}
+)
+
+// Validate checks that the notifier can be sent to
+func (n Notifier) Validate() error {
+	if n.Name == "" {
+		return errors.New("a notifier is missing a name")
+	} else if _, ok := Templates[n.Type]; !ok {
+		return fmt.Errorf("notifier %v has an unknown type %q, expected %v, %v or %v", n.Name, n.Type, TypeSlack, TypeTeams, TypeWebhook)
+	} else if n.URL == "" {
+		return fmt.Errorf("notifier %v is missing a url", n.Name)
+	}
+	for _, event := range n.Events {
+		if !contains(Events, event) {
+			return fmt.Errorf("notifier %v has an unknown event %q, expected one of %v", n.Name, event, strings.Join(Events, ", "))
+		}
+	}
+	return nil
+}
+
+// Wants is true when the notifier is subscribed to the event
+func (n Notifier) Wants(event string) bool {
+	return len(n.Events) == 0 || contains(n.Events, event)
+}
+
+// Payload renders the body that is sent for the event
+func (n Notifier) Payload(event Event) ([]byte, error) {
+	tmpl := n.Template
+	if tmpl == "" {
+		tmpl = Templates[n.Type]
+	}
+	out, err := term.Plain(tmpl, event)
+	if err != nil {
+		return nil, fmt.Errorf("rendering the template of notifier %v: %w", n.Name, err)
+	}
+	return []byte(out), nil
+}
+
+// Summary is a one line description of the event
+func (event Event) Summary() string {
+	name := event.ExtensionID
+	if event.Name != "" {
+		name = event.Name
+	}
+	if event.Version != "" {
+		name += " " + event.Version
+	}
+	var summary string
+	switch event.Event {
+	case EventUpload:
+		summary = fmt.Sprintf("%v was uploaded", name)
+	case EventPublish:
+		summary = fmt.Sprintf("%v was published", name)
+		if event.PublishTarget != "" {
+			summary += " to " + event.PublishTarget
+		}
+	case EventRejected:
+		summary = fmt.Sprintf("%v was rejected", name)
+	case EventRollout:
+		return fmt.Sprintf("%v is rolling out to %v%% of users", name, event.DeployPercentage)
+	default:
+		summary = fmt.Sprintf("%v: %v", name, event.Event)
+	}
+	if event.State != "" {
+		summary += fmt.Sprintf(" (%v)", event.State)
+	}
+	if len(event.Errors) > 0 {
+		summary += ": " + strings.Join(event.Errors, "; ")
+	}
+	return summary
+}
+
+// Send delivers the event to every notifier that wants it. Every notifier is
+// tried even if an earlier one fails, the failures are joined in the error.
+func (sender Sender) Send(notifiers []Notifier, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	errs := []error{}
+	for _, n := range notifiers {
+		if !n.Wants(event.Event) {
+			continue
+		}
+		if err := sender.send(n, event); err != nil {
+			errs = append(errs, fmt.Errorf("notifier %v: %w", n.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (sender Sender) send(n Notifier, event Event) error {
+	payload, err := n.Payload(event)
+	if err != nil {
+		return err
+	}
+	attempts, backoff := sender.Attempts, sender.Backoff
+	if attempts < 1 {
+		attempts = 3
+	}
+	if backoff == 0 {
+		backoff = time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		err = sender.post(n, payload)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+		time.Sleep(backoff << (attempt - 1))
+	}
+}
+
+func (sender Sender) post(n Notifier, payload []byte) error {
+	client := sender.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// the url is often a secret so it is left out of the error
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+func (err *statusError) Error() string {
+	if err.body == "" {
+		return fmt.Sprintf("responded with %v", err.code)
+	}
+	return fmt.Sprintf("responded with %v: %v", err.code, err.body)
+}
+
+// retryable is true for network errors, rate limits and server errors
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver records the requests it gets and responds with the given statuses
+// in order, and 200 when it runs out
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (rec *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	body, _ := io.ReadAll(req.Body)
+	rec.bodies = append(rec.bodies, string(body))
+	rec.headers = append(rec.headers, req.Header)
+	status := http.StatusOK
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+var testEvent = Event{
+	Event:       EventRejected,
+	ExtensionID: "abcdef",
+	Version:     "1.2.3",
+	State:       "FAILURE",
+	Errors:      []string{"ITEM_NOT_UPDATABLE: in review"},
+	Time:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+func TestPayloads(t *testing.T) {
+	for _, kind := range []string{TypeSlack, TypeTeams, TypeWebhook} {
+		payload, err := Notifier{Name: kind, Type: kind}.Payload(testEvent)
+		require.NoError(t, err)
+		assert.True(t, json.Valid(payload), "%v payload is not json: %s", kind, payload)
+		assert.Contains(t, string(payload), "abcdef", kind)
+	}
+
+	payload, err := Notifier{Name: "slack", Type: TypeSlack}.Payload(testEvent)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text": "abcdef 1.2.3 was rejected (FAILURE): ITEM_NOT_UPDATABLE: in review"}`, string(payload))
+
+	payload, err = Notifier{Name: "hook", Type: TypeWebhook}.Payload(testEvent)
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, testEvent, event)
+
+	custom := Notifier{Name: "custom", Type: TypeWebhook, Template: `{"msg": "{{.Version | bold}} {{.State}}"}`}
+	payload, err = custom.Payload(testEvent)
+	require.NoError(t, err)
+	assert.Equal(t, `{"msg": "1.2.3 FAILURE"}`, string(payload))
+
+	_, err = Notifier{Name: "broken", Type: TypeWebhook, Template: `{{.Nope}}`}.Payload(testEvent)
+	assert.ErrorContains(t, err, "notifier broken")
+}
+
+func TestSummary(t *testing.T) {
+	assert.Equal(t, "main 1.0 was uploaded (SUCCESS)", Event{Event: EventUpload, Name: "main", ExtensionID: "abc", Version: "1.0", State: "SUCCESS"}.Summary())
+	assert.Equal(t, "abc 1.0 was published to default (OK)", Event{Event: EventPublish, ExtensionID: "abc", Version: "1.0", State: "OK", PublishTarget: "default"}.Summary())
+	assert.Equal(t, "abc 1.0 is rolling out to 10% of users", Event{Event: EventRollout, ExtensionID: "abc", Version: "1.0", DeployPercentage: 10}.Summary())
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Notifier{Name: "a", Type: TypeSlack, URL: "https://hooks.slack.com/x"}.Validate())
+	assert.ErrorContains(t, Notifier{Type: TypeSlack, URL: "x"}.Validate(), "missing a name")
+	assert.ErrorContains(t, Notifier{Name: "a", Type: "email", URL: "x"}.Validate(), "unknown type")
+	assert.ErrorContains(t, Notifier{Name: "a", Type: TypeSlack}.Validate(), "missing a url")
+	assert.ErrorContains(t, Notifier{Name: "a", Type: TypeSlack, URL: "x", Events: []string{"deleted"}}.Validate(), "unknown event")
+}
+
+func TestSend(t *testing.T) {
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	notifiers := []Notifier{
+		{Name: "all", Type: TypeSlack, URL: server.URL, Headers: map[string]string{"X-Token": "secret"}},
+		{Name: "uploads", Type: TypeWebhook, URL: server.URL, Events: []string{EventUpload}},
+	}
+	sender := Sender{Client: server.Client(), Backoff: time.Millisecond}
+	require.NoError(t, sender.Send(notifiers, testEvent))
+	require.Len(t, rec.bodies, 1, "the uploads notifier does not want rejections")
+	assert.Equal(t, "secret", rec.headers[0].Get("X-Token"))
+	assert.Equal(t, "application/json", rec.headers[0].Get("Content-Type"))
+
+	require.NoError(t, sender.Send(notifiers, Event{Event: EventUpload, ExtensionID: "abcdef"}))
+	assert.Len(t, rec.bodies, 3)
+}
+
+func TestSendRetries(t *testing.T) {
+	rec := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	sender := Sender{Client: server.Client(), Backoff: time.Millisecond}
+	notifiers := []Notifier{{Name: "slack", Type: TypeSlack, URL: server.URL}}
+	require.NoError(t, sender.Send(notifiers, testEvent))
+	assert.Len(t, rec.bodies, 3)
+
+	rec.statuses = []int{http.StatusBadRequest}
+	rec.bodies = nil
+	err := sender.Send(notifiers, testEvent)
+	assert.ErrorContains(t, err, "notifier slack: responded with 400")
+	assert.Len(t, rec.bodies, 1, "client errors are not retried")
+
+	rec.statuses = []int{500, 500, 500}
+	rec.bodies = nil
+	err = sender.Send(notifiers, testEvent)
+	assert.ErrorContains(t, err, "responded with 500")
+	assert.Len(t, rec.bodies, 3)
+}
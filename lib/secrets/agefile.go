@@ -0,0 +1,176 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+)
+
+// AgeFile stores secrets in a single age encrypted json file. It is unlocked
+// with an age X25519 key when Key is set, otherwise with a passphrase. The file
+// is decrypted once and kept in memory for later reads.
+type AgeFile struct {
+	Path       string
+	Key        string
+	Passphrase func() (string, error)
+	// Confirm asks for the passphrase again when the file is created, it is
+	// not confirmed when Confirm is nil
+	Confirm func() (string, error)
+	pass    string
+	values  map[string]string
+}
+
+// Get reads a secret from the file
+func (file *AgeFile) Get(key string) (string, error) {
+	values, err := file.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Set saves a secret to the file, creating it if it does not exist
+func (file *AgeFile) Set(key, value string) error {
+	values, err := file.read()
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	return file.write(values)
+}
+
+// Delete removes a secret from the file
+func (file *AgeFile) Delete(key string) error {
+	values, err := file.read()
+	if err != nil {
+		return err
+	} else if _, ok := values[key]; !ok {
+		return ErrNotFound
+	}
+	delete(values, key)
+	return file.write(values)
+}
+
+func (file *AgeFile) read() (map[string]string, error) {
+	if file.values != nil {
+		return maps.Clone(file.values), nil
+	}
+	values := map[string]string{}
+	data, err := os.ReadFile(file.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+	identity, err := file.identity()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %v: %w", file.Path, err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %v: %w", file.Path, err)
+	}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return nil, fmt.Errorf("parsing %v: %w", file.Path, err)
+	}
+	file.values = maps.Clone(values)
+	return values, nil
+}
+
+func (file *AgeFile) write(values map[string]string) error {
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	recipient, err := file.recipient()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return err
+	} else if err := w.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file.Path, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	file.values = values
+	return nil
+}
+
+func (file *AgeFile) identity() (age.Identity, error) {
+	if file.Key != "" {
+		return age.ParseX25519Identity(file.Key)
+	}
+	pass, err := file.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return age.NewScryptIdentity(pass)
+}
+
+func (file *AgeFile) recipient() (age.Recipient, error) {
+	if file.Key != "" {
+		identity, err := age.ParseX25519Identity(file.Key)
+		if err != nil {
+			return nil, err
+		}
+		return identity.Recipient(), nil
+	}
+	// the passphrase was not asked to read the file, so it is being created
+	confirm := file.pass == "" && file.Confirm != nil
+	pass, err := file.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		again, err := file.Confirm()
+		if err != nil {
+			return nil, err
+		} else if again != pass {
+			file.pass = ""
+			return nil, errors.New("the passphrases do not match")
+		}
+	}
+	return age.NewScryptRecipient(pass)
+}
+
+// passphrase only asks once so that a read then write does not prompt twice
+func (file *AgeFile) passphrase() (string, error) {
+	if file.pass != "" {
+		return file.pass, nil
+	} else if file.Passphrase == nil {
+		return "", errors.New("no passphrase was given to unlock " + file.Path)
+	}
+	pass, err := file.Passphrase()
+	if err != nil {
+		return "", err
+	} else if pass == "" {
+		return "", errors.New("the passphrase cannot be empty")
+	}
+	file.pass = pass
+	return pass, nil
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticPassphrase(pass string) func() (string, error) {
+	return func() (string, error) { return pass, nil }
+}
+
+func TestAgeFilePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cws", "secrets.age")
+	file := &AgeFile{Path: path, Passphrase: staticPassphrase("hunter2")}
+
+	_, err := file.Get("prod/client_secret")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.Nil(t, file.Set("prod/client_secret", "s3cret"))
+	require.Nil(t, file.Set("prod/refresh_token", "t0ken"))
+
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+
+	reopened := &AgeFile{Path: path, Passphrase: staticPassphrase("hunter2")}
+	secret, err := reopened.Get("prod/client_secret")
+	require.Nil(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	require.Nil(t, reopened.Delete("prod/client_secret"))
+	_, err = reopened.Get("prod/client_secret")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	wrong := &AgeFile{Path: path, Passphrase: staticPassphrase("wrong")}
+	_, err = wrong.Get("prod/refresh_token")
+	assert.NotNil(t, err)
+}
+
+func TestAgeFileKey(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "secrets.age")
+	file := &AgeFile{Path: path, Key: identity.String()}
+	require.Nil(t, file.Set("client_secret", "s3cret"))
+
+	secret, err := (&AgeFile{Path: path, Key: identity.String()}).Get("client_secret")
+	require.Nil(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	_, err = (&AgeFile{Path: path, Passphrase: staticPassphrase("hunter2")}).Get("client_secret")
+	assert.NotNil(t, err)
+}
+
+func TestAgeFileConfirm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.age")
+	file := &AgeFile{Path: path, Passphrase: staticPassphrase("hunter2"), Confirm: staticPassphrase("hunter3")}
+	assert.EqualError(t, file.Set("client_secret", "s3cret"), "the passphrases do not match")
+	_, err := os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	file.Confirm = staticPassphrase("hunter2")
+	require.Nil(t, file.Set("client_secret", "s3cret"))
+
+	reopened := &AgeFile{Path: path, Passphrase: staticPassphrase("hunter2"), Confirm: staticPassphrase("never")}
+	require.Nil(t, reopened.Set("refresh_token", "t0ken"))
+}
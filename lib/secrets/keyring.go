@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Keyring stores secrets in the OS keyring, the freedesktop Secret Service
+// over D-Bus on linux. Keys are a service and an account separated by the
+// first slash, like cws/prod/client_secret.
+type Keyring struct{}
+
+// Get reads a secret from the keyring
+func (Keyring) Get(key string) (string, error) {
+	service, user, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+	secret, err := keyring.Get(service, user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("reading from the keyring: %w", err)
+	}
+	return secret, nil
+}
+
+// Set saves a secret to the keyring
+func (Keyring) Set(key, value string) error {
+	service, user, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(service, user, value); err != nil {
+		return fmt.Errorf("writing to the keyring: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a secret from the keyring
+func (Keyring) Delete(key string) error {
+	service, user, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Delete(service, user); errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("deleting from the keyring: %w", err)
+	}
+	return nil
+}
+
+func splitKey(key string) (service, user string, err error) {
+	service, user, found := strings.Cut(key, "/")
+	if !found || service == "" || user == "" {
+		return "", "", fmt.Errorf("invalid keyring key %q, expected service/name", key)
+	}
+	return service, user, nil
+}
@@ -0,0 +1,214 @@
+// Package secrets keeps credentials out of plaintext config files. Secrets are
+// saved to a Store and the config refers to them with a reference like
+// keyring:cws/prod/client_secret which is resolved when the config is loaded.
+// References can also point outside of a store, to the environment, a file or
+// the output of a helper command.
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store is a backend that secrets can be saved to and read from
+type Store interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// Store kinds, they double as the scheme of a reference to a secret in that store
+const (
+	KindKeyring   = "keyring"
+	KindAge       = "age"
+	KindPlaintext = "plaintext"
+)
+
+// ErrNotFound is returned when a secret is not in the store
+var ErrNotFound = errors.New("secret not found")
+
+// Options configures how stores are opened
+type Options struct {
+	// File is the path of the encrypted secrets file, defaults to DefaultFile
+	File string
+	// Passphrase is called when the encrypted file needs to be unlocked and no
+	// key is set in the environment, defaults to reading CWS_SECRETS_PASSPHRASE
+	Passphrase func() (string, error)
+	// ConfirmPassphrase is called when the encrypted file is created, to ask
+	// for the passphrase again so that a typo does not lock the secrets
+	ConfirmPassphrase func() (string, error)
+}
+
+// Stores opens each kind of store once, so that the encrypted file is only
+// unlocked once however many references point to it
+type Stores struct {
+	opts   Options
+	mu     sync.Mutex
+	opened map[string]Store
+}
+
+// Open returns the store of the given kind. Plaintext has no store, the
+// secret is written to the config as is.
+func Open(kind string, opts Options) (Store, error) {
+	switch kind {
+	case KindKeyring:
+		return Keyring{}, nil
+	case KindAge:
+		file := opts.File
+		if file == "" {
+			file = DefaultFile()
+		}
+		return &AgeFile{Path: file, Key: os.Getenv("CWS_SECRETS_KEY"), Passphrase: passphrase(opts), Confirm: opts.ConfirmPassphrase}, nil
+	case KindPlaintext:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown secret store %q, expected %v, %v or %v", kind, KindKeyring, KindAge, KindPlaintext)
+	}
+}
+
+// NewStores opens stores with opts as they are first needed
+func NewStores(opts Options) *Stores {
+	return &Stores{opts: opts, opened: map[string]Store{}}
+}
+
+// Open returns the store of the given kind, opening it the first time
+func (stores *Stores) Open(kind string) (Store, error) {
+	stores.mu.Lock()
+	defer stores.mu.Unlock()
+	if store, ok := stores.opened[kind]; ok {
+		return store, nil
+	}
+	store, err := Open(kind, stores.opts)
+	if err != nil {
+		return nil, err
+	}
+	stores.opened[kind] = store
+	return store, nil
+}
+
+// DefaultFile is the encrypted secrets file next to the user config, it can be
+// moved with CWS_SECRETS_FILE
+func DefaultFile() string {
+	if path := os.Getenv("CWS_SECRETS_FILE"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "cws", "secrets.age")
+}
+
+// Ref is the reference to key in the store of kind, to be saved in the config
+func Ref(kind, key string) string {
+	return kind + ":" + key
+}
+
+// ParseRef splits a reference into the kind of store and key. ok is false when
+// value is not a reference to a store.
+func ParseRef(value string) (kind, key string, ok bool) {
+	kind, key, found := strings.Cut(value, ":")
+	if !found || key == "" || (kind != KindKeyring && kind != KindAge) {
+		return "", "", false
+	}
+	return kind, key, true
+}
+
+// Resolve returns the value a reference points to. Besides references to a
+// store, env:NAME reads an environment variable, file:/path reads a file and
+// exec:command args runs a command and uses what it prints. Values that are not
+// references are returned unchanged.
+func Resolve(value string, opts Options) (string, error) {
+	return NewStores(opts).Resolve(value)
+}
+
+// Resolve returns the value a reference points to, like the package Resolve
+func (stores *Stores) Resolve(value string) (string, error) {
+	scheme, arg, _ := strings.Cut(value, ":")
+	if arg == "" {
+		return value, nil
+	}
+	switch scheme {
+	case "env":
+		secret, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("resolving %v: %w", value, ErrNotFound)
+		}
+		return secret, nil
+	case "file":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", fmt.Errorf("resolving %v: %w", value, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "exec":
+		return run(arg)
+	}
+	kind, key, ok := ParseRef(value)
+	if !ok {
+		return value, nil
+	}
+	store, err := stores.Open(kind)
+	if err != nil {
+		return "", err
+	}
+	secret, err := store.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("resolving %v: %w", value, err)
+	}
+	return secret, nil
+}
+
+// run executes a helper command for exec: references. The command is split on
+// whitespace and not run through a shell.
+func run(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("resolving exec: no command was given")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("resolving exec:%v: %w %v", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// Save puts the secret in the store of kind and returns the value to write to
+// the config in its place
+func Save(kind, key, secret string, opts Options) (string, error) {
+	return NewStores(opts).Save(kind, key, secret)
+}
+
+// Save puts the secret in the store of kind, like the package Save
+func (stores *Stores) Save(kind, key, secret string) (string, error) {
+	store, err := stores.Open(kind)
+	if err != nil || store == nil {
+		return secret, err
+	}
+	if err := store.Set(key, secret); err != nil {
+		return "", err
+	}
+	return Ref(kind, key), nil
+}
+
+func passphrase(opts Options) func() (string, error) {
+	if opts.Passphrase != nil {
+		return opts.Passphrase
+	}
+	return func() (string, error) {
+		if pass := os.Getenv("CWS_SECRETS_PASSPHRASE"); pass != "" {
+			return pass, nil
+		}
+		return "", errors.New("the secrets file is locked, set CWS_SECRETS_PASSPHRASE or CWS_SECRETS_KEY")
+	}
+}
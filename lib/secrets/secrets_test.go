@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func TestParseRef(t *testing.T) {
+	kind, key, ok := ParseRef("keyring:cws/prod")
+	assert.True(t, ok)
+	assert.Equal(t, KindKeyring, kind)
+	assert.Equal(t, "cws/prod", key)
+
+	for _, value := range []string{"plain-secret", "keyring:", "https://example.com", "plaintext:secret"} {
+		_, _, ok := ParseRef(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestSaveAndResolve(t *testing.T) {
+	keyring.MockInit()
+	opts := Options{File: filepath.Join(t.TempDir(), "secrets.age"), Passphrase: staticPassphrase("hunter2")}
+
+	ref, err := Save(KindKeyring, "cws/prod/client_secret", "from-keyring", opts)
+	require.Nil(t, err)
+	assert.Equal(t, "keyring:cws/prod/client_secret", ref)
+	secret, err := Resolve(ref, opts)
+	require.Nil(t, err)
+	assert.Equal(t, "from-keyring", secret)
+
+	ref, err = Save(KindAge, "prod/client_secret", "from-file", opts)
+	require.Nil(t, err)
+	assert.Equal(t, "age:prod/client_secret", ref)
+	secret, err = Resolve(ref, opts)
+	require.Nil(t, err)
+	assert.Equal(t, "from-file", secret)
+
+	ref, err = Save(KindPlaintext, "prod/client_secret", "plain", opts)
+	require.Nil(t, err)
+	assert.Equal(t, "plain", ref)
+	secret, err = Resolve(ref, opts)
+	require.Nil(t, err)
+	assert.Equal(t, "plain", secret)
+
+	_, err = Resolve("keyring:cws/missing", opts)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = Save("vault", "key", "value", opts)
+	assert.NotNil(t, err)
+}
+
+func TestResolveExternal(t *testing.T) {
+	t.Setenv("CWS_TEST_SECRET", "from-env")
+	path := filepath.Join(t.TempDir(), "secret")
+	require.Nil(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	for value, expected := range map[string]string{
+		"env:CWS_TEST_SECRET":  "from-env",
+		"file:" + path:         "from-file",
+		"exec:echo  from-exec": "from-exec",
+		"name:%v Beta":         "name:%v Beta",
+		"env:":                 "env:",
+	} {
+		secret, err := Resolve(value, Options{})
+		require.Nil(t, err, value)
+		assert.Equal(t, expected, secret, value)
+	}
+
+	_, err := Resolve("env:CWS_TEST_MISSING", Options{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = Resolve("file:"+path+".missing", Options{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = Resolve("exec:false", Options{})
+	assert.NotNil(t, err)
+}
+
+func TestStoresOpenOnce(t *testing.T) {
+	prompts := 0
+	stores := NewStores(Options{
+		File: filepath.Join(t.TempDir(), "secrets.age"),
+		Passphrase: func() (string, error) {
+			prompts++
+			return "hunter2", nil
+		},
+	})
+	for _, key := range []string{"prod/client_secret", "prod/refresh_token"} {
+		_, err := stores.Save(KindAge, key, "s3cret")
+		require.Nil(t, err)
+	}
+	for _, ref := range []string{"age:prod/client_secret", "age:prod/refresh_token"} {
+		secret, err := stores.Resolve(ref)
+		require.Nil(t, err)
+		assert.Equal(t, "s3cret", secret)
+	}
+	assert.Equal(t, 1, prompts)
+}
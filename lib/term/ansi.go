@@ -1,6 +1,8 @@
 package term
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -44,6 +46,11 @@ var funcMap = template.FuncMap{
 	"Cyan":      ansiStyler("46"),
 	"White":     ansiStyler("47"),
 	"spin":      spin,
+	"join":      join,
+	"json":      toJSON,
+	"size":      size,
+	"lines":     lines,
+	"hasPrefix": strings.HasPrefix,
 }
 
 var spinIndex int
@@ -55,6 +62,66 @@ func spin() string {
 	return string(spinGlyphs[spinIndex])
 }
 
+func join(sep string, vals []string) string {
+	return strings.Join(vals, sep)
+}
+
+// toJSON quotes a value so that it can be placed in a json template
+func toJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n"), err
+}
+
+// size formats a number of bytes for people, like 1.5 MB
+func size(v interface{}) string {
+	var bytes float64
+	fmt.Sscan(fmt.Sprint(v), &bytes)
+	sign := ""
+	if bytes < 0 {
+		sign, bytes = "-", -bytes
+	}
+	units := []string{"B", "KB", "MB", "GB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%v%.0f %v", sign, bytes, units[unit])
+	}
+	return fmt.Sprintf("%v%.1f %v", sign, bytes, units[unit])
+}
+
+// lines splits text into its lines without the line endings
+func lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Plain renders a template with the same functions as the terminal output but
+// without any color, for text that is sent elsewhere like notifications
+func Plain(in string, data interface{}) (string, error) {
+	funcs := template.FuncMap{}
+	for name, fn := range funcMap {
+		if _, isStyler := fn.(func(interface{}) string); isStyler {
+			fn = fmt.Sprint
+		}
+		funcs[name] = fn
+	}
+	tmpl, err := template.New("plain").Funcs(funcs).Parse(in)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
+
 type ansiStr struct {
 	str  string
 	vals []string
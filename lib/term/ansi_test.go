@@ -60,3 +60,19 @@ func TestWrapAnsi(t *testing.T) {
 	out := wrapANSI(str, 10)
 	assert.Equal(t, "\033[31;4mHello \033[1mWor\nld\033[m", out)
 }
+
+func TestPlain(t *testing.T) {
+	out, err := Plain(`{"text": {{printf "%v is %v" .Name (.State | green) | json}}}`, map[string]string{"Name": `my "ext"`, "State": "OK"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"text": "my \"ext\" is OK"}`, out)
+
+	_, err = Plain(`{{.Name`, nil)
+	assert.Error(t, err)
+}
+
+func TestSize(t *testing.T) {
+	assert.Equal(t, "0 B", size(0))
+	assert.Equal(t, "999 B", size(int64(999)))
+	assert.Equal(t, "1.5 KB", size(1536))
+	assert.Equal(t, "-2.0 MB", size(-2*1024*1024))
+}
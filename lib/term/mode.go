@@ -0,0 +1,65 @@
+package term
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+var (
+	quiet          bool
+	colorSet       bool
+	colorOn        bool
+	interactiveSet bool
+	interactiveOn  bool
+)
+
+// SetQuiet will suppress all progress output from Println and Spinner. Output
+// written with Fprintln is not affected.
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// SetColor will force colors on or off, overriding any detection from the
+// environment.
+func SetColor(enabled bool) {
+	colorSet, colorOn = true, enabled
+}
+
+// SetInteractive will force spinners to redraw, or to print plain lines, this
+// is useful when several spinners run at the same time and would overwrite
+// each other.
+func SetInteractive(enabled bool) {
+	interactiveSet, interactiveOn = true, enabled
+}
+
+// ColorEnabled reports if ANSI colors should be written to w. Colors are only
+// written to terminals unless overridden by SetColor, NO_COLOR, CLICOLOR_FORCE
+// or TERM=dumb.
+func ColorEnabled(w io.Writer) bool {
+	if colorSet {
+		return colorOn
+	} else if os.Getenv("NO_COLOR") != "" {
+		return false
+	} else if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	} else if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// Interactive reports if w is a terminal that supports redrawing lines. When it
+// is not, output falls back to plain lines.
+func Interactive(w io.Writer) bool {
+	if interactiveSet {
+		return interactiveOn
+	}
+	return os.Getenv("TERM") != "dumb" && isTerminal(w)
+}
+
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(interface{ Fd() uintptr })
+	return ok && term.IsTerminal(int(file.Fd()))
+}
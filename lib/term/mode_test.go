@@ -0,0 +1,96 @@
+package term
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setEnv(t *testing.T, noColor, force, termName string) {
+	t.Setenv("NO_COLOR", noColor)
+	t.Setenv("CLICOLOR_FORCE", force)
+	t.Setenv("TERM", termName)
+}
+
+func resetMode() {
+	quiet, colorSet, colorOn = false, false, false
+	interactiveSet, interactiveOn = false, false
+}
+
+func TestColorEnabled(t *testing.T) {
+	defer resetMode()
+	cases := []struct {
+		noColor, force, term string
+		expected             bool
+	}{
+		{"", "", "xterm", false},
+		{"", "1", "xterm", true},
+		{"", "0", "xterm", false},
+		{"1", "1", "xterm", false},
+		{"", "1", "dumb", true},
+		{"", "", "dumb", false},
+	}
+	for _, c := range cases {
+		setEnv(t, c.noColor, c.force, c.term)
+		assert.Equal(t, c.expected, ColorEnabled(&bytes.Buffer{}), "NO_COLOR=%q CLICOLOR_FORCE=%q TERM=%q", c.noColor, c.force, c.term)
+	}
+
+	setEnv(t, "", "1", "xterm")
+	SetColor(false)
+	assert.False(t, ColorEnabled(&bytes.Buffer{}))
+	setEnv(t, "1", "", "xterm")
+	SetColor(true)
+	assert.True(t, ColorEnabled(&bytes.Buffer{}))
+}
+
+func TestInteractive(t *testing.T) {
+	defer resetMode()
+	setEnv(t, "", "1", "xterm")
+	assert.False(t, Interactive(&bytes.Buffer{}))
+	SetInteractive(true)
+	assert.True(t, Interactive(&bytes.Buffer{}))
+	SetInteractive(false)
+	assert.False(t, Interactive(os.Stderr))
+}
+
+func TestFprintlnColor(t *testing.T) {
+	setEnv(t, "1", "", "xterm")
+	var buf bytes.Buffer
+	assert.Nil(t, Fprintln(&buf, `{{. | green}}`, "hello"))
+	assert.Equal(t, "hello\n", buf.String())
+
+	setEnv(t, "", "1", "xterm")
+	buf.Reset()
+	assert.Nil(t, Fprintln(&buf, `{{. | green}}`, "hello"))
+	assert.Equal(t, "\x1b[32mhello\x1b[m\n", buf.String())
+}
+
+func TestSpinnerLineMode(t *testing.T) {
+	setEnv(t, "", "", "xterm")
+	var buf bytes.Buffer
+	err := spinner(&buf, "Working", func() error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, "🔄 Working\n✅ Working\n", buf.String())
+
+	buf.Reset()
+	err = spinner(&buf, "Working", func() error { return errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, "🔄 Working\n🔥 Working\n", buf.String())
+}
+
+func TestSpinnerQuiet(t *testing.T) {
+	defer resetMode()
+	SetQuiet(true)
+	var buf bytes.Buffer
+	called := false
+	err := spinner(&buf, "Working", func() error {
+		called = true
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	assert.True(t, called)
+	assert.Equal(t, "", buf.String())
+}
@@ -0,0 +1,39 @@
+package term
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrNotInteractive is returned when input is needed but stdin is not a terminal
+var ErrNotInteractive = errors.New("cannot prompt for input, stdin is not a terminal")
+
+// Password prompts on stderr and reads a line from stdin without echoing it
+func Password(prompt string) (string, error) {
+	if !Interactive(os.Stdin) {
+		return "", ErrNotInteractive
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(pass), err
+}
+
+// Confirm asks a yes or no question on stderr, anything but y or yes is a no
+func Confirm(prompt string) (bool, error) {
+	if !Interactive(os.Stdin) {
+		return false, ErrNotInteractive
+	}
+	fmt.Fprint(os.Stderr, prompt+" [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
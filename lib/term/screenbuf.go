@@ -19,17 +19,30 @@ const (
 	defaultTermWidth = 80
 )
 
-// Println will print a formatted string out to a writer
+// Println will print a formatted string out to stderr, unless quiet
 func Println(in string, data interface{}, fs ...string) error {
-	sb := NewScreenBuf(os.Stderr, fs...)
+	if quiet {
+		return nil
+	}
+	return Fprintln(os.Stderr, in, data, fs...)
+}
+
+// Fprintln will print a formatted string out to a writer
+func Fprintln(w io.Writer, in string, data interface{}, fs ...string) error {
+	sb := NewScreenBuf(w, fs...)
 	sb.setWrap(false)
+	sb.setColor(ColorEnabled(w))
 	return sb.Render(in, data)
 }
 
-// PrintlnTmpl will print a formatted string out to a writer
+// PrintlnTmpl will print a formatted string out to stderr, unless quiet
 func PrintlnTmpl(tmpl string, data interface{}, fs ...string) error {
+	if quiet {
+		return nil
+	}
 	sb := NewScreenBuf(os.Stderr, fs...)
 	sb.setWrap(false)
+	sb.setColor(ColorEnabled(os.Stderr))
 	return sb.RenderTmpl(tmpl, data)
 }
 
@@ -43,9 +56,21 @@ func String(in string, data interface{}) string {
 	return buf.String()
 }
 
-// Spinner will print a formatted string with a spinner until the fn compeltes
+// Spinner will print a formatted string with a spinner until the fn compeltes.
+// If stderr is not a terminal, a line is printed when fn starts and completes
+// instead of redrawing the spinner.
 func Spinner(title string, fn func() error) error {
-	buf := NewScreenBuf(os.Stderr)
+	return spinner(os.Stderr, title, fn)
+}
+
+func spinner(w io.Writer, title string, fn func() error) error {
+	if quiet {
+		return fn()
+	} else if !Interactive(w) {
+		return lineSpinner(w, title, fn)
+	}
+	buf := NewScreenBuf(w)
+	buf.setColor(ColorEnabled(w))
 	ticker := time.NewTicker(25 * time.Millisecond)
 	go func() {
 		for {
@@ -68,15 +93,27 @@ func Spinner(title string, fn func() error) error {
 	return err
 }
 
+func lineSpinner(w io.Writer, title string, fn func() error) error {
+	Fprintln(w, `🔄 `+title, nil)
+	err := fn()
+	if err == nil {
+		Fprintln(w, `✅ `+title, nil)
+	} else {
+		Fprintln(w, `🔥 `+title, nil)
+	}
+	return err
+}
+
 // ScreenBuf is a convenient way to write to terminal screens. It creates,
 // clears and, moves up or down lines as needed to write the output to the
 // terminal using ANSI escape codes.
 type ScreenBuf struct {
-	w      io.Writer
-	buf    *bytes.Buffer
-	mut    sync.Mutex
-	tmpl   *template.Template
-	nowrap bool
+	w       io.Writer
+	buf     *bytes.Buffer
+	mut     sync.Mutex
+	tmpl    *template.Template
+	nowrap  bool
+	nocolor bool
 }
 
 // NewScreenBuf creates and initializes a new ScreenBuf.
@@ -92,6 +129,10 @@ func (s *ScreenBuf) setWrap(wrap bool) {
 	s.nowrap = !wrap
 }
 
+func (s *ScreenBuf) setColor(color bool) {
+	s.nocolor = !color
+}
+
 // Render will write a text/template out to the console, using a mutex so that
 // only a single writer at a time can write. This prevents the buffer from losing
 // sync with the newlines
@@ -158,8 +199,11 @@ func (s *ScreenBuf) write(in string, data interface{}) error {
 		width = defaultTermWidth
 	}
 	tmpl := in
+	if s.nocolor {
+		tmpl = string(removeANSI([]byte(tmpl)))
+	}
 	if !s.nowrap {
-		tmpl = wrapANSI(in, width)
+		tmpl = wrapANSI(tmpl, width)
 	}
 	if !strings.HasSuffix(tmpl, "\n") {
 		tmpl += "\n"